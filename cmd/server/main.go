@@ -10,20 +10,128 @@ import (
 	"syscall"
 	"time"
 
+	goredis "github.com/go-redis/redis/v8"
 	"github.com/wb-go/wbf/dbpg"
 	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/redis"
 	"github.com/wb-go/wbf/zlog"
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+	"github.com/yokitheyo/wb_level3_3/internal/events"
 	"github.com/yokitheyo/wb_level3_3/internal/handler/middleware"
+	infracache "github.com/yokitheyo/wb_level3_3/internal/infrastructure/cache"
 	infradatabase "github.com/yokitheyo/wb_level3_3/internal/infrastructure/database"
+	"github.com/yokitheyo/wb_level3_3/internal/infrastructure/notify"
+	"github.com/yokitheyo/wb_level3_3/internal/infrastructure/ratelimit"
 	"github.com/yokitheyo/wb_level3_3/internal/infrastructure/search"
+	"github.com/yokitheyo/wb_level3_3/internal/logging"
+	"github.com/yokitheyo/wb_level3_3/internal/metrics"
 	"github.com/yokitheyo/wb_level3_3/internal/retry"
+	"github.com/yokitheyo/wb_level3_3/internal/tracing"
 
 	"github.com/yokitheyo/wb_level3_3/internal/config"
 	httpHandler "github.com/yokitheyo/wb_level3_3/internal/handler/http"
+	"github.com/yokitheyo/wb_level3_3/internal/policy"
+	cacherepo "github.com/yokitheyo/wb_level3_3/internal/repository/cache"
+	"github.com/yokitheyo/wb_level3_3/internal/repository/instrumented"
 	"github.com/yokitheyo/wb_level3_3/internal/repository/postgres"
 	"github.com/yokitheyo/wb_level3_3/internal/usecase"
+	"github.com/yokitheyo/wb_level3_3/internal/worker"
 )
 
+// newRedisClient creates a Redis client with short timeouts: Redis is only a
+// cache and rate limit store here, and a stalled Redis must not stall requests.
+func newRedisClient(rc config.RedisConfig) *redis.Client {
+	return &redis.Client{Client: goredis.NewClient(&goredis.Options{
+		Addr:         rc.Addr,
+		Password:     rc.Password,
+		DB:           rc.DB,
+		DialTimeout:  time.Second,
+		ReadTimeout:  500 * time.Millisecond,
+		WriteTimeout: 500 * time.Millisecond,
+	})}
+}
+
+// rateLimitMiddleware builds a limiter for one route scope: Redis-backed when
+// a client is given, in-memory otherwise. Returns nil if the limit is disabled.
+func rateLimitMiddleware(client *redis.Client, prefix string, rc config.RateConfig) ginext.HandlerFunc {
+	if rc.PerMinute <= 0 || rc.Burst <= 0 {
+		return nil
+	}
+
+	rate := ratelimit.Rate{PerMinute: rc.PerMinute, Burst: rc.Burst}
+	var limiter ratelimit.Limiter = ratelimit.NewMemoryLimiter(rate)
+	if client != nil {
+		limiter = ratelimit.NewRedisLimiter(client, prefix, rate)
+	}
+	return middleware.RateLimitMiddleware(limiter)
+}
+
+// registerDBStats exposes connection pool stats of master and replicas.
+func registerDBStats(db *dbpg.DB) {
+	if err := metrics.RegisterDBStats(db.Master, "master"); err != nil {
+		zlog.Logger.Warn().Err(err).Msg("registering db stats failed")
+	}
+	for i, s := range db.Slaves {
+		if err := metrics.RegisterDBStats(s, fmt.Sprintf("replica_%d", i)); err != nil {
+			zlog.Logger.Warn().Err(err).Int("replica", i).Msg("registering db stats failed")
+		}
+	}
+}
+
+// readinessChecks lists the dependencies /readyz verifies.
+func readinessChecks(db *dbpg.DB, replicas *infradatabase.ReplicaSet, redisClient *redis.Client, migrationsDir string) []httpHandler.ReadinessCheck {
+	checks := []httpHandler.ReadinessCheck{
+		{
+			Name: "database",
+			Check: func(ctx context.Context) (interface{}, error) {
+				return nil, db.Master.PingContext(ctx)
+			},
+		},
+		{
+			Name: "migrations",
+			Check: func(ctx context.Context) (interface{}, error) {
+				current, latest, err := infradatabase.MigrationStatus(ctx, db.Master, migrationsDir)
+				details := map[string]int64{"current": current, "latest": latest}
+				if err != nil {
+					return details, err
+				}
+				if current < latest {
+					return details, fmt.Errorf("schema version %d is behind %d", current, latest)
+				}
+				return details, nil
+			},
+		},
+	}
+
+	if len(db.Slaves) > 0 {
+		// Reads fall back to master, so replicas never make us unready
+		checks = append(checks, httpHandler.ReadinessCheck{
+			Name:     "replicas",
+			Optional: true,
+			Check: func(context.Context) (interface{}, error) {
+				status := replicas.Status()
+				for _, s := range status {
+					if s.Healthy {
+						return status, nil
+					}
+				}
+				return status, fmt.Errorf("no healthy replicas, reads go to master")
+			},
+		})
+	}
+
+	if redisClient != nil {
+		checks = append(checks, httpHandler.ReadinessCheck{
+			Name: "redis",
+			Check: func(ctx context.Context) (interface{}, error) {
+				return nil, redisClient.Ping(ctx).Err()
+			},
+		})
+	}
+
+	return checks
+}
+
 // splitAndTrim splits s by sep and trims empty parts.
 func splitAndTrim(s, sep string) []string {
 	parts := strings.Split(s, sep)
@@ -55,8 +163,27 @@ func main() {
 	if err != nil {
 		zlog.Logger.Fatal().Err(err).Msg("failed to load config")
 	}
+	if err := logging.SetLevel(cfg.Logging.Level); err != nil {
+		zlog.Logger.Fatal().Err(err).Str("level", cfg.Logging.Level).Msg("invalid logging.level")
+	}
 	fmt.Printf("%+v\n", cfg.Database)
 
+	// Tracing
+	shutdownTracing := func(context.Context) error { return nil }
+	if cfg.Tracing.Enabled {
+		shutdownTracing, err = tracing.Setup(ctx, tracing.Options{
+			ServiceName:  cfg.Tracing.ServiceName,
+			Exporter:     cfg.Tracing.Exporter,
+			OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+			OTLPInsecure: cfg.Tracing.OTLPInsecure,
+			SampleRatio:  cfg.Tracing.SampleRatio,
+		})
+		if err != nil {
+			zlog.Logger.Fatal().Err(err).Msg("failed to set up tracing")
+		}
+		zlog.Logger.Info().Str("exporter", cfg.Tracing.Exporter).Msg("tracing enabled")
+	}
+
 	zlog.Logger.Info().Msgf("DSN from config: %s", cfg.Database.DSN)
 	zlog.Logger.Info().Msgf("Config retries: %d, delay: %d", cfg.Database.ConnectRetries, cfg.Database.ConnectRetryDelaySec)
 
@@ -139,18 +266,106 @@ func main() {
 
 	zlog.Logger.Info().Msg("Migrations completed successfully")
 
+	// Optional Redis, shared by rate limiting and the read cache
+	var redisClient *redis.Client
+	if cfg.Redis.Addr != "" {
+		redisClient = newRedisClient(cfg.Redis)
+		zlog.Logger.Info().Str("addr", cfg.Redis.Addr).Msg("redis client configured")
+	}
+
 	// Setup repository and usecase
-	repo := postgres.NewCommentRepository(database, retry.DefaultStrategy)
+	// Read routing: healthy replicas round-robin, master as fallback
+	replicas := infradatabase.NewReplicaSet(database.Master, database.Slaves)
+	if len(database.Slaves) > 0 {
+		checkInterval := time.Duration(cfg.Database.ReplicaCheckIntervalSec) * time.Second
+		replicas.Check(ctx, checkInterval)
+		go replicas.Run(ctx, checkInterval)
+	}
+
+	registerDBStats(database)
+
+	var repo domain.CommentRepository = postgres.NewCommentRepository(database, replicas, retry.DefaultStrategy)
+	repo = instrumented.NewCommentRepository(repo)
+	if cfg.Cache.Enabled {
+		var store infracache.Cache
+		if redisClient != nil {
+			store = infracache.NewRedisCache(redisClient, cfg.Cache.Prefix)
+			zlog.Logger.Info().Msg("comment cache enabled (redis)")
+		} else {
+			lru := infracache.NewLRUCache(cfg.Cache.MaxEntries)
+			if err := metrics.RegisterCacheStats(lru.Stats); err != nil {
+				zlog.Logger.Warn().Err(err).Msg("registering cache metrics failed")
+			}
+			store = lru
+			zlog.Logger.Info().Int("max_entries", cfg.Cache.MaxEntries).Msg("comment cache enabled (in-memory LRU)")
+		}
+		repo = cacherepo.NewCommentRepository(repo, store, time.Duration(cfg.Cache.TTLSec)*time.Second)
+	}
+
+	// Background purge of soft-deleted comments
+	purgerDone := make(chan struct{})
+	if cfg.Purge.Enabled && cfg.Purge.IntervalSec > 0 && cfg.Purge.BatchSize > 0 {
+		purger := worker.NewPurger(repo,
+			time.Duration(cfg.Purge.IntervalSec)*time.Second,
+			time.Duration(cfg.Purge.RetentionHours)*time.Hour,
+			cfg.Purge.BatchSize,
+		)
+		go func() {
+			defer close(purgerDone)
+			purger.Run(ctx)
+		}()
+	} else {
+		close(purgerDone)
+	}
 
 	// Full-text search adapter
 	fts := search.NewPostgresFullText(repo)
 
 	// Setup usecase с search
-	uc := usecase.NewCommentUsecase(repo, fts)
+	commentPolicy := policy.NewCommentPolicy(cfg.Auth.ModeratorRoles)
+	// Comment events: SSE subscribers read from the local bus. With notify
+	// enabled events go through pg_notify, and every instance, this one
+	// included, feeds its bus from LISTEN.
+	bus := events.NewBus(cfg.Stream.ReplaySize, cfg.Stream.SubscriberBuffer)
+	var publisher events.Publisher = bus
+	var listener *notify.Listener
+	listenerDone := make(chan struct{})
+	if cfg.Stream.Notify {
+		listener = notify.NewListener(cfg.Database.DSN, cfg.Stream.NotifyChannel, repo, bus)
+		publisher = notify.NewPublisher(database.Master, cfg.Stream.NotifyChannel, bus)
+		go func() {
+			defer close(listenerDone)
+			listener.Run(ctx)
+		}()
+	} else {
+		close(listenerDone)
+	}
+
+	uc := usecase.NewCommentUsecase(repo, fts, commentPolicy, publisher, usecase.Options{
+		MaxDepth:         cfg.Comments.MaxDepth,
+		MaxAuthorLength:  cfg.Comments.MaxAuthorLength,
+		MaxContentLength: cfg.Comments.MaxContentLength,
+	})
 
 	// Setup Gin engine + handlers
 	engine := ginext.New()
-	engine.Use(middleware.LoggerMiddleware(), middleware.CORSMiddleware())
+	// Handlers pass *gin.Context to the usecase as context.Context; fall back
+	// to the request context so values set by middleware (principal) are visible.
+	engine.ContextWithFallback = true
+	// Without trusted proxies ClientIP ignores X-Forwarded-For, otherwise any
+	// client could pick its own rate-limit key.
+	if err := engine.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		zlog.Logger.Fatal().Err(err).Strs("trusted_proxies", cfg.Server.TrustedProxies).Msg("invalid server.trusted_proxies")
+	}
+	engine.Use(
+		middleware.TracingMiddleware(),
+		middleware.RequestIDMiddleware(),
+		middleware.MetricsMiddleware(),
+		middleware.LoggerMiddleware(),
+		middleware.CORSMiddleware(),
+		middleware.BodyLimitMiddleware(cfg.Comments.MaxBodyBytes),
+		middleware.ReadPrimaryMiddleware(cfg.Database.ReadPrimaryAfterWriteSec),
+	)
 
 	engine.GET("/", func(c *ginext.Context) {
 		c.File("./static/index.html")
@@ -158,7 +373,42 @@ func main() {
 	engine.Static("/static", "./static")
 
 	commentHandler := httpHandler.NewCommentHandler(uc)
-	commentHandler.RegisterRoutes(engine)
+	apiKeys := make([]middleware.APIKey, 0, len(cfg.Auth.APIKeys))
+	for _, k := range cfg.Auth.APIKeys {
+		apiKeys = append(apiKeys, middleware.APIKey{Key: k.Key, Subject: k.Subject, Roles: k.Roles})
+	}
+	if cfg.Auth.JWTSecret == "" && len(apiKeys) == 0 {
+		zlog.Logger.Warn().Msg("auth: neither jwt_secret nor api_keys configured, write endpoints will reject all requests")
+	}
+
+	routeMW := httpHandler.RouteMiddlewares{
+		Auth: middleware.AuthMiddleware(cfg.Auth.JWTSecret, apiKeys),
+	}
+	if cfg.RateLimit.Enabled {
+		routeMW.CreateLimit = rateLimitMiddleware(redisClient, cfg.Cache.Prefix+"ratelimit:create:", cfg.RateLimit.Create)
+		routeMW.SearchLimit = rateLimitMiddleware(redisClient, cfg.Cache.Prefix+"ratelimit:search:", cfg.RateLimit.Search)
+	}
+	commentHandler.RegisterRoutes(engine, routeMW)
+
+	streamHandler := httpHandler.NewStreamHandler(uc, bus, time.Duration(cfg.Stream.HeartbeatSec)*time.Second, cfg.Stream.MaxConnectionsPerClient)
+	streamHandler.RegisterRoutes(engine)
+
+	checks := readinessChecks(database, replicas, redisClient, cfg.Migrations.Path)
+	if listener != nil {
+		// Without LISTEN only real-time delivery suffers, so it's optional
+		checks = append(checks, httpHandler.ReadinessCheck{
+			Name:     "events",
+			Optional: true,
+			Check: func(context.Context) (interface{}, error) {
+				if !listener.Connected() {
+					return nil, fmt.Errorf("not listening on %q", cfg.Stream.NotifyChannel)
+				}
+				return nil, nil
+			},
+		})
+	}
+	healthHandler := httpHandler.NewHealthHandler(checks...)
+	healthHandler.RegisterRoutes(engine)
 
 	// Start HTTP server
 	srv := &http.Server{
@@ -173,10 +423,39 @@ func main() {
 		}
 	}()
 
+	// Metrics live on their own listener so the public port doesn't expose them
+	var metricsSrv *http.Server
+	if cfg.Server.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		metricsSrv = &http.Server{
+			Addr:              cfg.Server.MetricsAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		go func() {
+			zlog.Logger.Info().Str("addr", cfg.Server.MetricsAddr).Msg("starting metrics server")
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				zlog.Logger.Fatal().Err(err).Msg("failed to start metrics server")
+			}
+		}()
+	}
+
 	// Graceful shutdown
 	<-ctx.Done()
 	zlog.Logger.Info().Msg("shutdown signal received")
 
+	// Fail readiness first and give load balancers time to stop routing to us
+	healthHandler.SetShuttingDown()
+	if drain := time.Duration(cfg.Server.ShutdownDrainSec) * time.Second; drain > 0 {
+		zlog.Logger.Info().Dur("drain", drain).Msg("readiness set to failing, draining")
+		time.Sleep(drain)
+	}
+
+	// SSE streams never finish on their own; end them so Shutdown doesn't
+	// wait for the whole timeout. Clients reconnect to another instance.
+	bus.Close()
+
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeoutSec)*time.Second)
 	defer cancel()
 
@@ -185,6 +464,20 @@ func main() {
 	} else {
 		zlog.Logger.Info().Msg("HTTP server stopped gracefully")
 	}
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(shutdownCtx); err != nil {
+			zlog.Logger.Error().Err(err).Msg("metrics server shutdown failed")
+		}
+	}
+
+	<-purgerDone
+	<-listenerDone
+
+	if redisClient != nil {
+		if err := redisClient.Close(); err != nil {
+			zlog.Logger.Error().Err(err).Msg("closing redis failed")
+		}
+	}
 
 	if database != nil && database.Master != nil {
 		if err := database.Master.Close(); err != nil {
@@ -199,5 +492,9 @@ func main() {
 		}
 	}
 
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		zlog.Logger.Error().Err(err).Msg("flushing traces failed")
+	}
+
 	zlog.Logger.Info().Msg("shutdown complete")
 }
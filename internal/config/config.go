@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/mitchellh/mapstructure"
 	wbfconf "github.com/wb-go/wbf/config"
 )
 
@@ -14,6 +15,13 @@ type Config struct {
 	Migrations MigrationsConfig `yaml:"migrations"`
 	Redis      RedisConfig      `yaml:"redis"`
 	Logging    LoggingConfig    `yaml:"logging"`
+	Comments   CommentsConfig   `yaml:"comments"`
+	Purge      PurgeConfig      `yaml:"purge"`
+	Auth       AuthConfig       `yaml:"auth"`
+	RateLimit  RateLimitConfig  `yaml:"rate_limit"`
+	Cache      CacheConfig      `yaml:"cache"`
+	Tracing    TracingConfig    `yaml:"tracing"`
+	Stream     StreamConfig     `yaml:"stream"`
 }
 
 type ServerConfig struct {
@@ -21,6 +29,16 @@ type ServerConfig struct {
 	ShutdownTimeoutSec int    `yaml:"shutdown_timeout_sec"`
 	ReadTimeoutSec     int    `yaml:"read_timeout_sec"`
 	WriteTimeoutSec    int    `yaml:"write_timeout_sec"`
+	// ShutdownDrainSec — пауза между переводом /readyz в отказ и остановкой
+	// сервера, чтобы балансировщик успел убрать экземпляр.
+	ShutdownDrainSec int `yaml:"shutdown_drain_sec"`
+	// TrustedProxies — адреса и подсети прокси, которым разрешено задавать
+	// IP клиента через X-Forwarded-For. Пусто — заголовку не верим, и IP
+	// клиента (ключ ограничения частоты) — адрес соединения.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// MetricsAddr — адрес отдельного листенера с /metrics. Порт не должен
+	// быть доступен снаружи. Пусто — метрики не отдаются.
+	MetricsAddr string `yaml:"metrics_addr"`
 }
 
 type DatabaseConfig struct {
@@ -31,6 +49,11 @@ type DatabaseConfig struct {
 	ConnMaxLifetimeSec   int    `yaml:"conn_max_lifetime_sec"`
 	ConnectRetries       int    `yaml:"connect_retries"`
 	ConnectRetryDelaySec int    `yaml:"connect_retry_delay_sec"`
+	// ReplicaCheckIntervalSec — период проверки реплик из slaves.
+	ReplicaCheckIntervalSec int `yaml:"replica_check_interval_sec"`
+	// ReadPrimaryAfterWriteSec — сколько секунд после записи чтения клиента
+	// идут на master, чтобы он увидел свои изменения.
+	ReadPrimaryAfterWriteSec int `yaml:"read_primary_after_write_sec"`
 }
 
 type MigrationsConfig struct {
@@ -43,14 +66,96 @@ type RedisConfig struct {
 	DB       int    `yaml:"db"`
 }
 
+// CacheConfig настраивает кэш чтения комментариев: в Redis, если задан
+// redis.addr, иначе в памяти процесса (LRU на MaxEntries записей).
 type CacheConfig struct {
-	Prefix string `yaml:"prefix"`
+	Enabled    bool   `yaml:"enabled"`
+	Prefix     string `yaml:"prefix"`
+	TTLSec     int    `yaml:"ttl_sec"`
+	MaxEntries int    `yaml:"max_entries"`
+}
+
+// PurgeConfig настраивает фоновую очистку мягко удалённых комментариев.
+type PurgeConfig struct {
+	Enabled        bool `yaml:"enabled"`
+	IntervalSec    int  `yaml:"interval_sec"`
+	RetentionHours int  `yaml:"retention_hours"`
+	BatchSize      int  `yaml:"batch_size"`
+}
+
+// AuthConfig задаёт способы аутентификации: JWT, подписанные HMAC-ключом
+// jwt_secret, и/или статические API-ключи.
+type AuthConfig struct {
+	JWTSecret string         `yaml:"jwt_secret"`
+	APIKeys   []APIKeyConfig `yaml:"api_keys"`
+	// ModeratorRoles — роли, которым разрешено править и удалять чужие комментарии.
+	ModeratorRoles []string `yaml:"moderator_roles"`
+}
+
+type APIKeyConfig struct {
+	Key     string   `yaml:"key"`
+	Subject string   `yaml:"subject"`
+	Roles   []string `yaml:"roles"`
+}
+
+// RateLimitConfig задаёт лимиты на создание комментариев и поиск. При
+// заданном redis.addr лимиты общие для всех экземпляров, иначе — на процесс.
+type RateLimitConfig struct {
+	Enabled bool       `yaml:"enabled"`
+	Create  RateConfig `yaml:"create"`
+	Search  RateConfig `yaml:"search"`
+}
+
+// RateConfig — параметры token bucket; нулевой PerMinute отключает лимит.
+type RateConfig struct {
+	PerMinute int `yaml:"per_minute"`
+	Burst     int `yaml:"burst"`
+}
+
+// TracingConfig настраивает экспорт трассировки OpenTelemetry: stdout для
+// локальной отладки или OTLP/HTTP коллектор.
+type TracingConfig struct {
+	Enabled      bool    `yaml:"enabled"`
+	ServiceName  string  `yaml:"service_name"`
+	Exporter     string  `yaml:"exporter"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint"`
+	OTLPInsecure bool    `yaml:"otlp_insecure"`
+	SampleRatio  float64 `yaml:"sample_ratio"`
+}
+
+// StreamConfig — параметры SSE-потока GET /comments/stream.
+type StreamConfig struct {
+	// ReplaySize — сколько последних событий хранится для досылки по
+	// Last-Event-ID.
+	ReplaySize int `yaml:"replay_size"`
+	// SubscriberBuffer — очередь событий клиента; отставший сильнее клиент
+	// отключается и переподключается.
+	SubscriberBuffer int `yaml:"subscriber_buffer"`
+	HeartbeatSec     int `yaml:"heartbeat_sec"`
+	// MaxConnectionsPerClient — сколько потоков одновременно может держать
+	// один IP, 0 — без ограничения. Поток не требует аутентификации, поэтому
+	// клиент различается только по IP: включайте предел, лишь если
+	// server.trusted_proxies перечисляет балансировщики, иначе все клиенты
+	// за балансировщиком или NAT делят один предел.
+	MaxConnectionsPerClient int `yaml:"max_connections_per_client"`
+	// Notify включает рассылку событий между экземплярами через
+	// LISTEN/NOTIFY в канале NotifyChannel.
+	Notify        bool   `yaml:"notify"`
+	NotifyChannel string `yaml:"notify_channel"`
 }
 
 type LoggingConfig struct {
 	Level string `yaml:"level"`
 }
 
+type CommentsConfig struct {
+	// MaxDepth — максимальная глубина вложенности ответов, 0 — без ограничения.
+	MaxDepth         int   `yaml:"max_depth"`
+	MaxAuthorLength  int   `yaml:"max_author_length"`
+	MaxContentLength int   `yaml:"max_content_length"`
+	MaxBodyBytes     int64 `yaml:"max_body_bytes"`
+}
+
 func Load(path string) (*Config, error) {
 	cfgw := wbfconf.New()
 
@@ -72,14 +177,21 @@ func Load(path string) (*Config, error) {
 		}
 	}
 
+	// viper по умолчанию сопоставляет поля по mapstructure-тегам, а не по yaml,
+	// из-за чего ключи вида connect_retries не попадали в структуру.
 	var cfg Config
-	if err := cfgw.Unmarshal(&cfg); err != nil {
+	if err := cfgw.Unmarshal(&cfg, func(dc *mapstructure.DecoderConfig) {
+		dc.TagName = "yaml"
+	}); err != nil {
 		return nil, err
 	}
 
 	if dsn := os.Getenv("DATABASE_DSN"); dsn != "" {
 		cfg.Database.DSN = dsn
 	}
+	if secret := os.Getenv("AUTH_JWT_SECRET"); secret != "" {
+		cfg.Auth.JWTSecret = secret
+	}
 
 	if cfg.Database.ConnectRetries == 0 {
 		cfg.Database.ConnectRetries = 20
@@ -96,6 +208,9 @@ func Load(path string) (*Config, error) {
 	if cfg.Database.ConnMaxLifetimeSec == 0 {
 		cfg.Database.ConnMaxLifetimeSec = 1800
 	}
+	if cfg.Database.ReplicaCheckIntervalSec <= 0 {
+		cfg.Database.ReplicaCheckIntervalSec = 5
+	}
 
 	if strings.TrimSpace(cfg.Database.DSN) == "" {
 		return nil, errors.New("database.dsn is required (set in config file or DATABASE_DSN env)")
@@ -107,8 +222,10 @@ func Load(path string) (*Config, error) {
 func setDefaults(c *wbfconf.Config) {
 	c.SetDefault("server.addr", ":8080")
 	c.SetDefault("server.shutdown_timeout_sec", 15)
+	c.SetDefault("server.shutdown_drain_sec", 5)
 	c.SetDefault("server.read_timeout_sec", 10)
 	c.SetDefault("server.write_timeout_sec", 10)
+	c.SetDefault("server.metrics_addr", ":9090")
 
 	c.SetDefault("database.dsn", "")
 	c.SetDefault("database.slaves", "")
@@ -117,6 +234,8 @@ func setDefaults(c *wbfconf.Config) {
 	c.SetDefault("database.conn_max_lifetime_sec", 1800)
 	c.SetDefault("database.connect_retries", 20)
 	c.SetDefault("database.connect_retry_delay_sec", 5)
+	c.SetDefault("database.replica_check_interval_sec", 5)
+	c.SetDefault("database.read_primary_after_write_sec", 5)
 
 	c.SetDefault("migrations.path", "./migrations")
 
@@ -124,7 +243,43 @@ func setDefaults(c *wbfconf.Config) {
 	c.SetDefault("redis.password", "")
 	c.SetDefault("redis.db", 0)
 
+	c.SetDefault("cache.enabled", true)
 	c.SetDefault("cache.prefix", "ct:")
+	c.SetDefault("cache.ttl_sec", 60)
+	c.SetDefault("cache.max_entries", 10000)
 
 	c.SetDefault("logging.level", "info")
+
+	c.SetDefault("tracing.enabled", false)
+	c.SetDefault("tracing.service_name", "commenttree")
+	c.SetDefault("tracing.exporter", "stdout")
+	c.SetDefault("tracing.otlp_endpoint", "")
+	c.SetDefault("tracing.otlp_insecure", true)
+	c.SetDefault("tracing.sample_ratio", 1.0)
+
+	c.SetDefault("stream.replay_size", 1000)
+	c.SetDefault("stream.subscriber_buffer", 64)
+	c.SetDefault("stream.heartbeat_sec", 15)
+	c.SetDefault("stream.max_connections_per_client", 0)
+	c.SetDefault("stream.notify", true)
+	c.SetDefault("stream.notify_channel", "comment_events")
+
+	c.SetDefault("comments.max_depth", 0)
+	c.SetDefault("comments.max_author_length", 100)
+	c.SetDefault("comments.max_content_length", 5000)
+	c.SetDefault("comments.max_body_bytes", 64<<10)
+
+	c.SetDefault("purge.enabled", true)
+	c.SetDefault("purge.interval_sec", 3600)
+	c.SetDefault("purge.retention_hours", 720)
+	c.SetDefault("purge.batch_size", 500)
+
+	c.SetDefault("auth.jwt_secret", "")
+	c.SetDefault("auth.moderator_roles", []string{"moderator", "admin"})
+
+	c.SetDefault("rate_limit.enabled", true)
+	c.SetDefault("rate_limit.create.per_minute", 10)
+	c.SetDefault("rate_limit.create.burst", 5)
+	c.SetDefault("rate_limit.search.per_minute", 60)
+	c.SetDefault("rate_limit.search.burst", 20)
 }
@@ -10,5 +10,31 @@ type Comment struct {
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt *time.Time `json:"updated_at,omitempty"`
 	Deleted   bool       `json:"deleted"`
-	Children  []*Comment `json:"children,omitempty"`
+	// Locked запрещает отвечать на комментарий.
+	Locked bool `json:"locked"`
+	// RestoredAt и RestoredBy — кто и когда последним восстановил комментарий.
+	RestoredAt *time.Time `json:"restored_at,omitempty"`
+	RestoredBy string     `json:"restored_by,omitempty"`
+	// LiveDescendants — число неудалённых потомков. Удалённый комментарий
+	// без них скрыт из выдачи.
+	LiveDescendants int `json:"live_descendants,omitempty"`
+	// Owner — subject пользователя, создавшего комментарий. В отличие от
+	// Author не показывается и задаётся только из аутентификации; пустой
+	// у комментариев, созданных до его появления.
+	Owner string `json:"owner,omitempty"`
+	// Depth и Path заполняются при выборке поддерева: глубина относительно
+	// корня выборки и цепочка id от корня до самого комментария.
+	Depth    int        `json:"depth,omitempty"`
+	Path     []int64    `json:"path,omitempty"`
+	Children []*Comment `json:"children,omitempty"`
+}
+
+// Tombstone стирает содержимое удалённого комментария, оставляя его место
+// в дереве, чтобы ответы на него не потеряли контекст.
+func (c *Comment) Tombstone() {
+	if !c.Deleted {
+		return
+	}
+	c.Content = ""
+	c.Author = ""
 }
@@ -0,0 +1,28 @@
+package domain
+
+import "fmt"
+
+// DeleteMode задаёт, что именно удаляет DeleteThread.
+type DeleteMode string
+
+const (
+	// DeleteSingle мягко удаляет один комментарий; ответы остаются.
+	DeleteSingle DeleteMode = "single"
+	// DeleteSubtree мягко удаляет комментарий вместе со всеми ответами.
+	DeleteSubtree DeleteMode = "subtree"
+	// DeletePurge безвозвратно удаляет комментарий; ответы удаляются
+	// каскадно внешним ключом.
+	DeletePurge DeleteMode = "purge"
+)
+
+// ParseDeleteMode разбирает режим удаления; пустая строка — DeleteSingle.
+func ParseDeleteMode(s string) (DeleteMode, error) {
+	switch mode := DeleteMode(s); mode {
+	case "":
+		return DeleteSingle, nil
+	case DeleteSingle, DeleteSubtree, DeletePurge:
+		return mode, nil
+	default:
+		return "", NewValidationError("mode", fmt.Sprintf("unknown delete mode %q", s))
+	}
+}
@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+)
+
+// Ошибки предметной области. Usecase и репозиторий оборачивают их через
+// fmt.Errorf("...: %w", Err...), HTTP-слой сопоставляет их со статусами.
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrValidation    = errors.New("validation failed")
+	ErrConflict      = errors.New("conflict")
+	ErrParentDeleted = errors.New("parent comment is deleted")
+	ErrParentLocked  = errors.New("parent comment is locked")
+	ErrUnauthorized  = errors.New("authentication required")
+	ErrForbidden     = errors.New("forbidden")
+)
+
+// IsExpected сообщает, что err — ошибка предметной области, то есть ошибка
+// клиента, а не сбой.
+func IsExpected(err error) bool {
+	for _, target := range []error{
+		ErrNotFound, ErrValidation, ErrConflict, ErrParentDeleted,
+		ErrParentLocked, ErrUnauthorized, ErrForbidden,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldError описывает ошибку в конкретном поле запроса.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationError — ошибка валидации с перечнем полей.
+// errors.Is(err, ErrValidation) для неё истинно.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// NewValidationError создаёт ошибку валидации одного поля.
+func NewValidationError(field, message string) *ValidationError {
+	return &ValidationError{Fields: []FieldError{{Field: field, Message: message}}}
+}
+
+// Add добавляет ошибку поля.
+func (e *ValidationError) Add(field, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+// OrNil возвращает nil, если ошибок полей нет.
+func (e *ValidationError) OrNil() error {
+	if e == nil || len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+": "+f.Message)
+	}
+	return ErrValidation.Error() + ": " + strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+// Cursor — позиция keyset-пагинации: ключ последнего отданного комментария.
+// Rank задаётся только для выдачи полнотекстового поиска, упорядоченной
+// по релевантности.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+	Rank      *float64
+}
+
+// Page описывает запрашиваемую страницу. Если задан After, выборка идёт
+// по ключу (created_at, id) и Offset игнорируется.
+type Page struct {
+	Limit  int
+	Offset int
+	Sort   string
+	After  *Cursor
+}
+
+// NextCursor возвращает курсор следующей страницы или nil, если страница
+// неполная и продолжения нет.
+func NextCursor(items []*Comment, limit int) *Cursor {
+	if limit <= 0 || len(items) < limit {
+		return nil
+	}
+	last := items[len(items)-1]
+	return &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+}
@@ -0,0 +1,32 @@
+package domain
+
+import "context"
+
+// Principal — аутентифицированный автор запроса.
+type Principal struct {
+	Subject string
+	Roles   []string
+}
+
+// HasRole сообщает, есть ли у пользователя роль role.
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal кладёт пользователя в контекст запроса.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext достаёт пользователя, положенного WithPrincipal.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
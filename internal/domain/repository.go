@@ -1,11 +1,46 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type CommentRepository interface {
+	// WithinTx выполняет fn в одной транзакции: методы репозитория, вызванные
+	// с контекстом, переданным в fn, работают внутри неё.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
 	Save(ctx context.Context, comment *Comment) error
 	FindByID(ctx context.Context, id int64) (*Comment, error)
-	FindChildren(ctx context.Context, parentID *int64, limit, offset int, sort string) ([]*Comment, error)
-	Delete(ctx context.Context, id int64) error
-	Search(ctx context.Context, query string, limit, offset int) ([]*Comment, error)
+	// LockForReply блокирует комментарий до конца транзакции, чтобы его не
+	// удалили и не закрыли, пока сохраняется ответ, и возвращает его с
+	// заполненным Depth — числом предков.
+	LockForReply(ctx context.Context, id int64) (*Comment, error)
+	// FindRootID возвращает id комментария верхнего уровня, с которого
+	// начинается тред комментария id.
+	FindRootID(ctx context.Context, id int64) (int64, error)
+	// FindAncestorIDs возвращает id комментария и всех его предков до корня
+	// треда. Читает с master: вызывается при записи.
+	FindAncestorIDs(ctx context.Context, id int64) ([]int64, error)
+	// FindChildren и FindDescendants отдают удалённые комментарии, только если
+	// у них остались неудалённые потомки.
+	FindChildren(ctx context.Context, parentID *int64, page Page) ([]*Comment, error)
+	// FindDescendants возвращает всех потомков указанных комментариев одним
+	// запросом. maxDepth <= 0 означает без ограничения глубины.
+	FindDescendants(ctx context.Context, rootIDs []int64, maxDepth int) ([]*Comment, error)
+	// Update заменяет текст комментария, сохраняя прежний в истории правок.
+	Update(ctx context.Context, comment *Comment) error
+	FindRevisions(ctx context.Context, commentID int64) ([]*Revision, error)
+	// Delete удаляет комментарий в режиме mode и возвращает число
+	// затронутых комментариев.
+	Delete(ctx context.Context, id int64, mode DeleteMode) (int64, error)
+	// Restore снимает мягкое удаление с комментария (и с его поддерева при
+	// subtree), записывая, кто восстановил, и возвращает число затронутых.
+	Restore(ctx context.Context, id int64, subtree bool, restoredBy string) (int64, error)
+	// SetLocked закрывает (locked) или открывает комментарий для ответов и
+	// возвращает его новое состояние.
+	SetLocked(ctx context.Context, id int64, locked bool) (*Comment, error)
+	// PurgeDeleted безвозвратно удаляет до limit комментариев, мягко
+	// удалённых раньше olderThan и не имеющих ответов.
+	PurgeDeleted(ctx context.Context, olderThan time.Time, limit int) (int64, error)
+	Search(ctx context.Context, query string, page Page) ([]*SearchHit, error)
 }
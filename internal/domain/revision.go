@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// Revision — прежний текст комментария, сохранённый при редактировании.
+// EditedAt — момент, когда этот текст был заменён новым.
+type Revision struct {
+	ID        int64     `json:"id"`
+	CommentID int64     `json:"comment_id"`
+	Content   string    `json:"content"`
+	EditedAt  time.Time `json:"edited_at"`
+}
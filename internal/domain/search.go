@@ -0,0 +1,26 @@
+package domain
+
+// SearchHit — найденный комментарий с фрагментом совпадения и релевантностью.
+// Snippet — HTML-экранированный фрагмент текста, совпадения обёрнуты в <mark>.
+type SearchHit struct {
+	Comment *Comment
+	Snippet string
+	Rank    float64
+	// Ranked — хит найден полнотекстовым поиском. У хитов ILIKE-фолбэка
+	// ранга нет, и следующая страница листается по (created_at, id).
+	Ranked bool
+}
+
+// NextSearchCursor возвращает курсор следующей страницы поиска или nil.
+func NextSearchCursor(hits []*SearchHit, limit int) *Cursor {
+	if limit <= 0 || len(hits) < limit {
+		return nil
+	}
+	last := hits[len(hits)-1]
+	cursor := &Cursor{CreatedAt: last.Comment.CreatedAt, ID: last.Comment.ID}
+	if last.Ranked {
+		rank := last.Rank
+		cursor.Rank = &rank
+	}
+	return cursor
+}
@@ -3,8 +3,24 @@ package domain
 import "context"
 
 type CommentService interface {
-	CreateComment(ctx context.Context, parentID *int64, author, content string) (*Comment, error)
-	GetThread(ctx context.Context, parentID *int64, limit, offset int, sort string) ([]*Comment, error)
-	DeleteThread(ctx context.Context, id int64) error
-	SearchComment(ctx context.Context, query string, limit, offset int) ([]*Comment, error)
+	// CreateComment создаёт комментарий от имени пользователя из контекста
+	// (см. WithPrincipal).
+	CreateComment(ctx context.Context, parentID *int64, content string) (*Comment, error)
+	// GetThread возвращает страницу комментариев с поддеревьями и курсор
+	// следующей страницы (nil, если страница последняя).
+	GetThread(ctx context.Context, parentID *int64, page Page) ([]*Comment, *Cursor, error)
+	// GetComment возвращает комментарий с потомками до depth уровней
+	// (0 — без потомков). Удалённый комментарий без неудалённых потомков
+	// скрыт, как и в выдаче, — для него возвращается ErrNotFound.
+	GetComment(ctx context.Context, id int64, depth int) (*Comment, error)
+	EditComment(ctx context.Context, id int64, content string) (*Comment, error)
+	// GetRevisions возвращает прежние версии текста владельцу комментария и
+	// модератору. Для удалённого комментария история доступна только
+	// модератору, остальным возвращается ErrNotFound.
+	GetRevisions(ctx context.Context, id int64) ([]*Revision, error)
+	DeleteThread(ctx context.Context, id int64, mode DeleteMode) (int64, error)
+	RestoreComment(ctx context.Context, id int64, subtree bool) (int64, error)
+	// LockComment закрывает комментарий для ответов (locked) или открывает.
+	LockComment(ctx context.Context, id int64, locked bool) (*Comment, error)
+	SearchComment(ctx context.Context, query string, page Page) ([]*SearchHit, *Cursor, error)
 }
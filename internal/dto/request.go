@@ -2,6 +2,13 @@ package dto
 
 type CreateCommentRequest struct {
 	ParentID *int64 `json:"parent_id,omitempty"`
-	Author   string `json:"author"`
 	Content  string `json:"content"`
 }
+
+type UpdateCommentRequest struct {
+	Content string `json:"content"`
+}
+
+type RestoreCommentRequest struct {
+	Subtree bool `json:"subtree"`
+}
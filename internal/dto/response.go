@@ -3,12 +3,93 @@ package dto
 import "time"
 
 type CommentResponse struct {
-	ID        int64              `json:"id"`
-	ParentID  *int64             `json:"parent_id,omitempty"`
-	Content   string             `json:"content"`
-	Author    string             `json:"author"`
-	CreatedAt time.Time          `json:"created_at"`
-	UpdatedAt *time.Time         `json:"updated_at,omitempty"`
-	Deleted   bool               `json:"deleted"`
-	Children  []*CommentResponse `json:"children,omitempty"`
+	ID         int64              `json:"id"`
+	ParentID   *int64             `json:"parent_id,omitempty"`
+	Content    string             `json:"content"`
+	Author     string             `json:"author"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  *time.Time         `json:"updated_at,omitempty"`
+	Deleted    bool               `json:"deleted"`
+	Locked     bool               `json:"locked"`
+	RestoredAt *time.Time         `json:"restored_at,omitempty"`
+	RestoredBy string             `json:"restored_by,omitempty"`
+	Children   []*CommentResponse `json:"children,omitempty"`
+}
+
+// CommentPageResponse — страница комментариев при курсорной пагинации.
+type CommentPageResponse struct {
+	Items      []*CommentResponse `json:"items"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+type RevisionResponse struct {
+	ID        int64     `json:"id"`
+	CommentID int64     `json:"comment_id"`
+	Content   string    `json:"content"`
+	EditedAt  time.Time `json:"edited_at"`
+}
+
+// SearchHitResponse — комментарий из выдачи поиска. Snippet — HTML-фрагмент
+// с совпадениями в <mark>, текст в нём уже экранирован.
+type SearchHitResponse struct {
+	CommentResponse
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
+}
+
+// SearchPageResponse — страница поиска при курсорной пагинации.
+type SearchPageResponse struct {
+	Items      []*SearchHitResponse `json:"items"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// ErrorResponse — тело ответа с ошибкой. Code — машиночитаемый код,
+// Fields заполняется для ошибок валидации.
+type ErrorResponse struct {
+	Error  string               `json:"error"`
+	Code   string               `json:"code"`
+	Fields []FieldErrorResponse `json:"fields,omitempty"`
+}
+
+type FieldErrorResponse struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// DeleteResponse сообщает, сколько комментариев затронуло удаление.
+type DeleteResponse struct {
+	ID       int64  `json:"id"`
+	Mode     string `json:"mode"`
+	Affected int64  `json:"affected"`
+}
+
+type RestoreResponse struct {
+	ID       int64 `json:"id"`
+	Affected int64 `json:"affected"`
+}
+
+// CommentEventResponse — данные SSE-события об изменении комментария.
+// Comment заполняется для создания и правки, Mode и Affected — для удаления.
+type CommentEventResponse struct {
+	Type      string           `json:"type"`
+	ThreadID  int64            `json:"thread_id"`
+	CommentID int64            `json:"comment_id"`
+	ParentID  *int64           `json:"parent_id,omitempty"`
+	Comment   *CommentResponse `json:"comment,omitempty"`
+	Mode      string           `json:"mode,omitempty"`
+	Affected  int64            `json:"affected,omitempty"`
+	At        time.Time        `json:"at"`
+}
+
+// HealthResponse — ответ /healthz и /readyz.
+type HealthResponse struct {
+	Status string                  `json:"status"`
+	Checks map[string]*CheckResult `json:"checks,omitempty"`
+}
+
+// CheckResult — результат одной проверки готовности.
+type CheckResult struct {
+	Status  string      `json:"status"`
+	Error   string      `json:"error,omitempty"`
+	Details interface{} `json:"details,omitempty"`
 }
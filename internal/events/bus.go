@@ -0,0 +1,182 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrClosed возвращается при подписке на остановленную шину.
+var ErrClosed = errors.New("event bus closed")
+
+// Bus — шина событий в памяти процесса. Последние события хранятся в
+// кольцевом буфере, чтобы переподключившийся клиент получил пропущенное.
+//
+// ID события имеет вид "<epoch>-<seq>": epoch меняется при каждом запуске,
+// поэтому Last-Event-ID от прежнего процесса не спутать с текущим.
+type Bus struct {
+	mu     sync.Mutex
+	epoch  string
+	seq    uint64
+	replay []Event
+	next   int // позиция в replay для следующего события
+	size   int // число событий в replay
+	subs   map[*Subscription]struct{}
+	buffer int
+	closed bool
+}
+
+// NewBus создаёт шину, хранящую replaySize последних событий. subscriberBuffer —
+// размер очереди подписчика: не успевающий читать подписчик отключается,
+// а клиент переподключается и дочитывает из буфера.
+func NewBus(replaySize, subscriberBuffer int) *Bus {
+	if replaySize <= 0 {
+		replaySize = 1
+	}
+	if subscriberBuffer <= 0 {
+		subscriberBuffer = 1
+	}
+	return &Bus{
+		epoch:  strconv.FormatInt(time.Now().UnixNano(), 36),
+		replay: make([]Event, replaySize),
+		subs:   make(map[*Subscription]struct{}),
+		buffer: subscriberBuffer,
+	}
+}
+
+// Publish присваивает событию ID, сохраняет его в буфере и рассылает
+// подписчикам треда. Подписчики с переполненной очередью отключаются.
+func (b *Bus) Publish(_ context.Context, e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	b.seq++
+	e.ID = b.epoch + "-" + strconv.FormatUint(b.seq, 10)
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+
+	b.replay[b.next] = e
+	b.next = (b.next + 1) % len(b.replay)
+	if b.size < len(b.replay) {
+		b.size++
+	}
+
+	for s := range b.subs {
+		if !s.matches(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			b.drop(s)
+		}
+	}
+}
+
+// Subscribe подписывает на события треда thread (0 — на все треды).
+// Если lastEventID не пуст, возвращает события после него из буфера;
+// complete = false значит, что часть событий уже вытеснена или ID от
+// другого запуска, и клиенту нужно перечитать тред целиком.
+func (b *Bus) Subscribe(thread int64, lastEventID string) (sub *Subscription, replay []Event, complete bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, nil, false, ErrClosed
+	}
+
+	sub = &Subscription{thread: thread, bus: b, ch: make(chan Event, b.buffer)}
+	b.subs[sub] = struct{}{}
+
+	if lastEventID == "" {
+		return sub, nil, true, nil
+	}
+	after, ok := b.parseID(lastEventID)
+	if !ok || after > b.seq {
+		return sub, nil, false, nil
+	}
+	oldest := b.seq - uint64(b.size) + 1
+	if after+1 < oldest {
+		return sub, nil, false, nil
+	}
+
+	for i := 0; i < b.size; i++ {
+		e := b.replay[(b.next-b.size+i+len(b.replay))%len(b.replay)]
+		if oldest+uint64(i) > after && sub.matches(e) {
+			replay = append(replay, e)
+		}
+	}
+	return sub, replay, true, nil
+}
+
+// Reset сбрасывает буфер и отключает подписчиков, начиная новую эпоху ID.
+// Нужен, когда события могли потеряться (например, при обрыве LISTEN):
+// переподключившиеся клиенты получат reset и перечитают тред.
+func (b *Bus) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.epoch = strconv.FormatInt(time.Now().UnixNano(), 36)
+	clear(b.replay)
+	b.next, b.size = 0, 0
+	for s := range b.subs {
+		b.drop(s)
+	}
+}
+
+// Close отключает всех подписчиков; дальнейшие публикации игнорируются.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		b.drop(s)
+	}
+}
+
+func (b *Bus) parseID(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != b.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	return n, err == nil
+}
+
+// drop закрывает очередь подписчика; вызывается под b.mu.
+func (b *Bus) drop(s *Subscription) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	close(s.ch)
+}
+
+// Subscription — подписка на события. Канал Events закрывается, когда
+// подписчик отстал или шина остановлена.
+type Subscription struct {
+	thread int64
+	bus    *Bus
+	ch     chan Event
+}
+
+// Events возвращает канал событий подписки.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Close отменяет подписку.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.drop(s)
+}
+
+func (s *Subscription) matches(e Event) bool {
+	return s.thread == 0 || s.thread == e.ThreadID
+}
@@ -0,0 +1,205 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func publishN(b *Bus, thread int64, n int) {
+	for i := 0; i < n; i++ {
+		b.Publish(context.Background(), Event{Type: CommentCreated, ThreadID: thread, CommentID: int64(i + 1)})
+	}
+}
+
+// eventID собирает ID события seq текущей эпохи шины.
+func eventID(b *Bus, seq uint64) string {
+	return b.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+func commentIDs(events []Event) []int64 {
+	ids := make([]int64, len(events))
+	for i, e := range events {
+		ids[i] = e.CommentID
+	}
+	return ids
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSubscribeReplay(t *testing.T) {
+	tests := []struct {
+		name         string
+		published    int
+		lastSeq      uint64
+		wantIDs      []int64
+		wantComplete bool
+	}{
+		{"before wrap", 3, 1, []int64{2, 3}, true},
+		{"up to date", 3, 3, nil, true},
+		{"after wrap", 7, 4, []int64{5, 6, 7}, true},
+		{"after wrap from oldest kept", 7, 3, []int64{4, 5, 6, 7}, true},
+		{"evicted", 7, 2, nil, false},
+		{"future id", 3, 10, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBus(4, 8)
+			publishN(b, 1, tt.published)
+
+			sub, replay, complete, err := b.Subscribe(0, eventID(b, tt.lastSeq))
+			if err != nil {
+				t.Fatalf("Subscribe: %v", err)
+			}
+			defer sub.Close()
+
+			if complete != tt.wantComplete {
+				t.Errorf("complete = %v, want %v", complete, tt.wantComplete)
+			}
+			if got := commentIDs(replay); !equalIDs(got, tt.wantIDs) {
+				t.Errorf("replay = %v, want %v", got, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestSubscribeReplayFiltersThread(t *testing.T) {
+	b := NewBus(8, 8)
+	for i, thread := range []int64{1, 2, 1, 2, 1} {
+		b.Publish(context.Background(), Event{Type: CommentCreated, ThreadID: thread, CommentID: int64(i + 1)})
+	}
+
+	sub, replay, complete, err := b.Subscribe(1, eventID(b, 1))
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Close()
+
+	if !complete {
+		t.Error("complete = false, want true")
+	}
+	if got, want := commentIDs(replay), []int64{3, 5}; !equalIDs(got, want) {
+		t.Errorf("replay = %v, want %v", got, want)
+	}
+
+	b.Publish(context.Background(), Event{ThreadID: 2, CommentID: 6})
+	b.Publish(context.Background(), Event{ThreadID: 1, CommentID: 7})
+	if e := <-sub.Events(); e.CommentID != 7 {
+		t.Errorf("delivered comment %d, want 7", e.CommentID)
+	}
+}
+
+func TestSubscribeMalformedID(t *testing.T) {
+	b := NewBus(4, 8)
+	publishN(b, 1, 2)
+
+	for _, id := range []string{"garbage", b.epoch + "-x", "-1", "otherepoch-1"} {
+		sub, replay, complete, err := b.Subscribe(0, id)
+		if err != nil {
+			t.Fatalf("Subscribe(%q): %v", id, err)
+		}
+		if complete || replay != nil {
+			t.Errorf("Subscribe(%q) = (%v, %v), want reset", id, replay, complete)
+		}
+		sub.Close()
+	}
+}
+
+func TestResetStartsNewEpoch(t *testing.T) {
+	b := NewBus(4, 8)
+	publishN(b, 1, 3)
+	before := eventID(b, 3)
+
+	sub, _, _, err := b.Subscribe(0, "")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	b.Reset()
+
+	if _, ok := <-sub.Events(); ok {
+		t.Error("subscription still open after Reset")
+	}
+
+	// Reset может произойти в ту же наносекунду, что и NewBus; эпоху
+	// подменяем, чтобы проверка не зависела от часов.
+	b.epoch += "r"
+	_, replay, complete, err := b.Subscribe(0, before)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if complete || replay != nil {
+		t.Errorf("Subscribe after Reset = (%v, %v), want reset", replay, complete)
+	}
+
+	b.Publish(context.Background(), Event{ThreadID: 1, CommentID: 10})
+	_, replay, complete, err = b.Subscribe(0, eventID(b, 3))
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if !complete || !equalIDs(commentIDs(replay), []int64{10}) {
+		t.Errorf("replay in new epoch = (%v, %v), want ([10], true)", commentIDs(replay), complete)
+	}
+	if !strings.HasPrefix(replay[0].ID, b.epoch+"-") {
+		t.Errorf("event id %q is not from epoch %q", replay[0].ID, b.epoch)
+	}
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	b := NewBus(16, 2)
+	slow, _, _, err := b.Subscribe(0, "")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	fast, _, _, err := b.Subscribe(0, "")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer fast.Close()
+
+	for i := 1; i <= 3; i++ {
+		b.Publish(context.Background(), Event{ThreadID: 1, CommentID: int64(i)})
+		<-fast.Events()
+	}
+
+	var got []int64
+	for e := range slow.Events() {
+		got = append(got, e.CommentID)
+	}
+	if want := []int64{1, 2}; !equalIDs(got, want) {
+		t.Errorf("slow subscriber got %v before drop, want %v", got, want)
+	}
+	slow.Close()
+
+	b.Publish(context.Background(), Event{ThreadID: 1, CommentID: 4})
+	if e, ok := <-fast.Events(); !ok || e.CommentID != 4 {
+		t.Errorf("fast subscriber got (%v, %v), want comment 4", e.CommentID, ok)
+	}
+}
+
+func TestSubscribeAfterClose(t *testing.T) {
+	b := NewBus(4, 8)
+	sub, _, _, err := b.Subscribe(0, "")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	b.Close()
+
+	if _, ok := <-sub.Events(); ok {
+		t.Error("subscription still open after Close")
+	}
+	if _, _, _, err := b.Subscribe(0, ""); !errors.Is(err, ErrClosed) {
+		t.Errorf("Subscribe after Close: %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,46 @@
+// Package events доставляет изменения комментариев подписчикам в реальном
+// времени: CommentUsecase публикует события, а SSE-обработчик раздаёт их
+// клиентам, подписанным на тред.
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+)
+
+// Type — вид изменения комментария.
+type Type string
+
+const (
+	CommentCreated  Type = "comment.created"
+	CommentEdited   Type = "comment.edited"
+	CommentDeleted  Type = "comment.deleted"
+	CommentRestored Type = "comment.restored"
+)
+
+// Event — изменение комментария CommentID в треде ThreadID (id комментария
+// верхнего уровня).
+type Event struct {
+	// ID присваивает Bus при публикации; клиент возвращает его в
+	// Last-Event-ID, чтобы продолжить поток после переподключения.
+	ID        string
+	Type      Type
+	ThreadID  int64
+	CommentID int64
+	ParentID  *int64
+	// Comment — состояние комментария после создания, правки или
+	// восстановления.
+	Comment *domain.Comment
+	// Mode заполняется для удаления, Affected — для удаления и
+	// восстановления.
+	Mode     domain.DeleteMode
+	Affected int64
+	At       time.Time
+}
+
+// Publisher принимает события для доставки подписчикам.
+type Publisher interface {
+	Publish(ctx context.Context, e Event)
+}
@@ -5,7 +5,6 @@ import (
 	"strconv"
 
 	"github.com/wb-go/wbf/ginext"
-	"github.com/wb-go/wbf/zlog"
 	"github.com/yokitheyo/wb_level3_3/internal/domain"
 	"github.com/yokitheyo/wb_level3_3/internal/dto"
 )
@@ -20,27 +19,53 @@ func NewCommentHandler(service domain.CommentService) *CommentHandler {
 	return &CommentHandler{service: service}
 }
 
-func (h *CommentHandler) RegisterRoutes(engine *ginext.Engine) {
+// RouteMiddlewares — middleware, навешиваемые на отдельные маршруты.
+// Nil-поля пропускаются.
+type RouteMiddlewares struct {
+	// Auth требует аутентификации; ставится на изменяющие маршруты и на
+	// историю правок.
+	Auth ginext.HandlerFunc
+	// CreateLimit и SearchLimit ограничивают частоту создания комментариев
+	// и поиска. CreateLimit идёт после Auth, чтобы лимит считался по пользователю.
+	CreateLimit ginext.HandlerFunc
+	SearchLimit ginext.HandlerFunc
+}
+
+func (h *CommentHandler) RegisterRoutes(engine *ginext.Engine, mw RouteMiddlewares) {
 	group := engine.Group("/comments")
-	group.POST("", h.CreateComment)
+	group.POST("", chain(mw.Auth, mw.CreateLimit, h.CreateComment)...)
 	group.GET("", h.GetComments)
-	group.DELETE("/:id", h.DeleteComment)
-	group.GET("/search", h.SearchComments)
+	group.GET("/:id", h.GetComment)
+	group.PATCH("/:id", chain(mw.Auth, h.EditComment)...)
+	group.GET("/:id/revisions", chain(mw.Auth, h.GetRevisions)...)
+	group.DELETE("/:id", chain(mw.Auth, h.DeleteComment)...)
+	group.POST("/:id/restore", chain(mw.Auth, h.RestoreComment)...)
+	group.POST("/:id/lock", chain(mw.Auth, h.LockComment)...)
+	group.POST("/:id/unlock", chain(mw.Auth, h.UnlockComment)...)
+	group.GET("/search", chain(mw.SearchLimit, h.SearchComments)...)
+}
+
+// chain собирает цепочку обработчиков маршрута, пропуская nil.
+func chain(handlers ...ginext.HandlerFunc) []ginext.HandlerFunc {
+	out := make([]ginext.HandlerFunc, 0, len(handlers))
+	for _, h := range handlers {
+		if h != nil {
+			out = append(out, h)
+		}
+	}
+	return out
 }
 
 // CreateComment POST /comments
 func (h *CommentHandler) CreateComment(c *ginext.Context) {
 	var req dto.CreateCommentRequest
-	if err := c.BindJSON(&req); err != nil {
-		zlog.Logger.Warn().Err(err).Msg("invalid request body")
-		c.JSON(http.StatusBadRequest, ginext.H{"error": "invalid request"})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	comment, err := h.service.CreateComment(c, req.ParentID, req.Author, req.Content)
+	comment, err := h.service.CreateComment(c, req.ParentID, req.Content)
 	if err != nil {
-		zlog.Logger.Error().Err(err).Msg("CreateComment failed")
-		c.JSON(http.StatusInternalServerError, ginext.H{"error": "failed to create comment"})
+		writeError(c, err, "failed to create comment")
 		return
 	}
 
@@ -48,89 +73,223 @@ func (h *CommentHandler) CreateComment(c *ginext.Context) {
 
 }
 
-// GetComments GET /comments?parent={id}&limit=&offset=&sort=
+// GetComments GET /comments?parent={id}&limit=&offset=&sort=&cursor=
 func (h *CommentHandler) GetComments(c *ginext.Context) {
 	var parentID *int64
 	if parentStr := c.Query("parent"); parentStr != "" {
 		id, err := strconv.ParseInt(parentStr, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, ginext.H{"error": "invalid parent id"})
+			writeBadRequest(c, "parent", "must be an integer")
 			return
 		}
 		parentID = &id
 	}
 
-	limit := 10
-	if l := c.Query("limit"); l != "" {
-		if val, err := strconv.Atoi(l); err == nil {
-			limit = val
-		}
+	page, cursorMode, err := parsePage(c)
+	if err != nil {
+		writeError(c, err, "")
+		return
+	}
+
+	comments, next, err := h.service.GetThread(c, parentID, page)
+	if err != nil {
+		writeError(c, err, "failed to get comments")
+		return
+	}
+
+	if cursorMode {
+		c.JSON(http.StatusOK, &dto.CommentPageResponse{
+			Items:      mapToCommentResponses(comments),
+			NextCursor: encodeCursor(next),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapToCommentResponses(comments))
+}
+
+// GetComment GET /comments/:id?depth=
+//
+// depth больше maxDepth урезается до maxDepth.
+func (h *CommentHandler) GetComment(c *ginext.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "id", "must be an integer")
+		return
 	}
-	offset := 0
-	if o := c.Query("offset"); o != "" {
-		if val, err := strconv.Atoi(o); err == nil {
-			offset = val
+
+	depth := 0
+	if d := c.Query("depth"); d != "" {
+		val, err := strconv.Atoi(d)
+		if err != nil || val < 0 {
+			writeBadRequest(c, "depth", "must be a non-negative integer")
+			return
 		}
+		depth = min(val, maxDepth)
 	}
-	sort := c.Query("sort")
 
-	comments, err := h.service.GetThread(c, parentID, limit, offset, sort)
+	comment, err := h.service.GetComment(c, id, depth)
 	if err != nil {
-		zlog.Logger.Error().Err(err).Msg("GetThread failed")
-		c.JSON(http.StatusInternalServerError, ginext.H{"error": "failed to get comments"})
+		writeError(c, err, "failed to get comment")
 		return
 	}
 
-	c.JSON(http.StatusOK, mapToCommentResponses(comments))
+	c.JSON(http.StatusOK, mapToCommentResponse(comment))
+}
+
+// EditComment PATCH /comments/:id
+func (h *CommentHandler) EditComment(c *ginext.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "id", "must be an integer")
+		return
+	}
+
+	var req dto.UpdateCommentRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	comment, err := h.service.EditComment(c, id, req.Content)
+	if err != nil {
+		writeError(c, err, "failed to edit comment")
+		return
+	}
+
+	c.JSON(http.StatusOK, mapToCommentResponse(comment))
+}
+
+// GetRevisions GET /comments/:id/revisions
+func (h *CommentHandler) GetRevisions(c *ginext.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "id", "must be an integer")
+		return
+	}
+
+	revisions, err := h.service.GetRevisions(c, id)
+	if err != nil {
+		writeError(c, err, "failed to get revisions")
+		return
+	}
+
+	out := make([]*dto.RevisionResponse, 0, len(revisions))
+	for _, r := range revisions {
+		out = append(out, &dto.RevisionResponse{
+			ID:        r.ID,
+			CommentID: r.CommentID,
+			Content:   r.Content,
+			EditedAt:  r.EditedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, out)
 }
 
-// DeleteComment DELETE /comments/:id
+// DeleteComment DELETE /comments/:id?mode=single|subtree|purge
 func (h *CommentHandler) DeleteComment(c *ginext.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ginext.H{"error": "invalid id"})
+		writeBadRequest(c, "id", "must be an integer")
+		return
+	}
+
+	mode, err := domain.ParseDeleteMode(c.Query("mode"))
+	if err != nil {
+		writeError(c, err, "")
+		return
+	}
+
+	affected, err := h.service.DeleteThread(c, id, mode)
+	if err != nil {
+		writeError(c, err, "failed to delete comment")
+		return
+	}
+
+	c.JSON(http.StatusOK, &dto.DeleteResponse{ID: id, Mode: string(mode), Affected: affected})
+}
+
+// RestoreComment POST /comments/:id/restore
+func (h *CommentHandler) RestoreComment(c *ginext.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "id", "must be an integer")
+		return
+	}
+
+	var req dto.RestoreCommentRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
+		return
+	}
+
+	affected, err := h.service.RestoreComment(c, id, req.Subtree)
+	if err != nil {
+		writeError(c, err, "failed to restore comment")
+		return
+	}
+
+	c.JSON(http.StatusOK, &dto.RestoreResponse{ID: id, Affected: affected})
+}
+
+// LockComment POST /comments/:id/lock
+func (h *CommentHandler) LockComment(c *ginext.Context) {
+	h.setLocked(c, true)
+}
+
+// UnlockComment POST /comments/:id/unlock
+func (h *CommentHandler) UnlockComment(c *ginext.Context) {
+	h.setLocked(c, false)
+}
+
+func (h *CommentHandler) setLocked(c *ginext.Context, locked bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeBadRequest(c, "id", "must be an integer")
 		return
 	}
 
-	if err := h.service.DeleteThread(c, id); err != nil {
-		zlog.Logger.Error().Err(err).Msg("DeleteThread failed")
-		c.JSON(http.StatusInternalServerError, ginext.H{"error": "failed to delete comment"})
+	comment, err := h.service.LockComment(c, id, locked)
+	if err != nil {
+		writeError(c, err, "failed to change comment lock")
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	c.JSON(http.StatusOK, mapToCommentResponse(comment))
 }
 
-// SearchComments GET /comments/search?query=&limit=&offset=
+// SearchComments GET /comments/search?query=&limit=&offset=&cursor=
+//
+// Ищет только по тексту комментариев; по имени автора комментарии не
+// находятся.
 func (h *CommentHandler) SearchComments(c *ginext.Context) {
 	query := c.Query("query")
 	if query == "" {
-		c.JSON(http.StatusBadRequest, ginext.H{"error": "query cannot be empty"})
+		writeBadRequest(c, "query", "required")
 		return
 	}
 
-	limit := 10
-	if l := c.Query("limit"); l != "" {
-		if val, err := strconv.Atoi(l); err == nil {
-			limit = val
-		}
-	}
-	offset := 0
-	if o := c.Query("offset"); o != "" {
-		if val, err := strconv.Atoi(o); err == nil {
-			offset = val
-		}
+	page, cursorMode, err := parsePage(c)
+	if err != nil {
+		writeError(c, err, "")
+		return
 	}
 
-	comments, err := h.service.SearchComment(c, query, limit, offset)
+	hits, next, err := h.service.SearchComment(c, query, page)
 	if err != nil {
-		zlog.Logger.Error().Err(err).Msg("SearchComment failed")
-		c.JSON(http.StatusInternalServerError, ginext.H{"error": "search failed"})
+		writeError(c, err, "search failed")
+		return
+	}
+
+	if cursorMode {
+		c.JSON(http.StatusOK, &dto.SearchPageResponse{
+			Items:      mapToSearchHitResponses(hits),
+			NextCursor: encodeCursor(next),
+		})
 		return
 	}
 
-	c.JSON(http.StatusOK, comments)
+	c.JSON(http.StatusOK, mapToSearchHitResponses(hits))
 }
 
 func mapToCommentResponse(c *domain.Comment) *dto.CommentResponse {
@@ -144,14 +303,17 @@ func mapToCommentResponse(c *domain.Comment) *dto.CommentResponse {
 	}
 
 	return &dto.CommentResponse{
-		ID:        c.ID,
-		ParentID:  c.ParentID,
-		Content:   c.Content,
-		Author:    c.Author,
-		CreatedAt: c.CreatedAt,
-		UpdatedAt: c.UpdatedAt,
-		Deleted:   c.Deleted,
-		Children:  children,
+		ID:         c.ID,
+		ParentID:   c.ParentID,
+		Content:    c.Content,
+		Author:     c.Author,
+		CreatedAt:  c.CreatedAt,
+		UpdatedAt:  c.UpdatedAt,
+		Deleted:    c.Deleted,
+		Locked:     c.Locked,
+		RestoredAt: c.RestoredAt,
+		RestoredBy: c.RestoredBy,
+		Children:   children,
 	}
 }
 
@@ -162,3 +324,15 @@ func mapToCommentResponses(list []*domain.Comment) []*dto.CommentResponse {
 	}
 	return out
 }
+
+func mapToSearchHitResponses(hits []*domain.SearchHit) []*dto.SearchHitResponse {
+	out := make([]*dto.SearchHitResponse, 0, len(hits))
+	for _, h := range hits {
+		out = append(out, &dto.SearchHitResponse{
+			CommentResponse: *mapToCommentResponse(h.Comment),
+			Snippet:         h.Snippet,
+			Rank:            h.Rank,
+		})
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wb-go/wbf/ginext"
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+)
+
+const (
+	defaultLimit = 10
+	maxLimit     = 100
+	maxDepth     = 50
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor упаковывает курсор в непрозрачный токен для клиента.
+func encodeCursor(c *domain.Cursor) string {
+	if c == nil {
+		return ""
+	}
+	raw := fmt.Sprintf("%d:%d", c.CreatedAt.UnixMicro(), c.ID)
+	if c.Rank != nil {
+		raw += ":" + strconv.FormatFloat(*c.Rank, 'g', -1, 64)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor разбирает токен, выданный encodeCursor.
+func decodeCursor(token string) (*domain.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, errInvalidCursor
+	}
+	micros, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	commentID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	cursor := &domain.Cursor{CreatedAt: time.UnixMicro(micros).UTC(), ID: commentID}
+	if len(parts) == 3 {
+		rank, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return nil, errInvalidCursor
+		}
+		cursor.Rank = &rank
+	}
+	return cursor, nil
+}
+
+// parsePage читает limit/offset/sort/cursor из query. Второй результат
+// сообщает, запросил ли клиент курсорную пагинацию: присутствие параметра
+// cursor (даже пустого) переключает ответ на обёртку с next_cursor, старые
+// клиенты с offset получают прежний массив. Некорректные значения — ошибка
+// валидации с именем параметра. limit больше maxLimit в курсорном режиме —
+// тоже ошибка, а в offset-режиме, как и раньше, урезается до maxLimit, чтобы
+// не ломать существующих клиентов.
+func parsePage(c *ginext.Context) (domain.Page, bool, error) {
+	page := domain.Page{Limit: defaultLimit, Sort: c.Query("sort")}
+	token, cursorMode := c.GetQuery("cursor")
+
+	if l := c.Query("limit"); l != "" {
+		val, err := strconv.Atoi(l)
+		if err != nil || val < 1 || (cursorMode && val > maxLimit) {
+			return page, cursorMode, domain.NewValidationError("limit", fmt.Sprintf("must be an integer between 1 and %d", maxLimit))
+		}
+		page.Limit = min(val, maxLimit)
+	}
+
+	if cursorMode {
+		if token != "" {
+			after, err := decodeCursor(token)
+			if err != nil {
+				return page, true, domain.NewValidationError("cursor", err.Error())
+			}
+			page.After = after
+		}
+		return page, true, nil
+	}
+
+	if o := c.Query("offset"); o != "" {
+		val, err := strconv.Atoi(o)
+		if err != nil || val < 0 {
+			return page, false, domain.NewValidationError("offset", "must be a non-negative integer")
+		}
+		page.Offset = val
+	}
+	return page, false, nil
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	rank := 0.0759909
+	created := time.Date(2025, 3, 1, 12, 30, 45, 123456000, time.UTC)
+
+	tests := []struct {
+		name   string
+		cursor *domain.Cursor
+	}{
+		{"keyset", &domain.Cursor{CreatedAt: created, ID: 42}},
+		{"with rank", &domain.Cursor{CreatedAt: created, ID: 7, Rank: &rank}},
+		{"zero time", &domain.Cursor{CreatedAt: time.UnixMicro(0).UTC(), ID: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(encodeCursor(tt.cursor))
+			if err != nil {
+				t.Fatalf("decodeCursor: %v", err)
+			}
+			if !got.CreatedAt.Equal(tt.cursor.CreatedAt) || got.ID != tt.cursor.ID {
+				t.Errorf("got (%v, %d), want (%v, %d)", got.CreatedAt, got.ID, tt.cursor.CreatedAt, tt.cursor.ID)
+			}
+			switch {
+			case tt.cursor.Rank == nil && got.Rank != nil:
+				t.Errorf("rank = %v, want nil", *got.Rank)
+			case tt.cursor.Rank != nil && (got.Rank == nil || *got.Rank != *tt.cursor.Rank):
+				t.Errorf("rank = %v, want %v", got.Rank, *tt.cursor.Rank)
+			}
+		})
+	}
+}
+
+func TestEncodeCursorNil(t *testing.T) {
+	if got := encodeCursor(nil); got != "" {
+		t.Errorf("encodeCursor(nil) = %q, want empty", got)
+	}
+}
+
+func TestDecodeCursorMalformed(t *testing.T) {
+	enc := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := map[string]string{
+		"not base64":     "!!!",
+		"padded base64":  base64.URLEncoding.EncodeToString([]byte("1:2:")),
+		"one part":       enc("123"),
+		"four parts":     enc("1:2:3:4"),
+		"bad time":       enc("x:2"),
+		"bad id":         enc("1:y"),
+		"bad rank":       enc("1:2:z"),
+		"empty":          enc(""),
+		"trailing colon": enc("1:2:"),
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := decodeCursor(token); !errors.Is(err, errInvalidCursor) {
+				t.Errorf("decodeCursor(%q) error = %v, want errInvalidCursor", token, err)
+			}
+		})
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		query      string
+		wantLimit  int
+		wantOffset int
+		wantCursor bool
+		wantField  string
+	}{
+		{query: "", wantLimit: defaultLimit},
+		{query: "limit=1&offset=20", wantLimit: 1, wantOffset: 20},
+		{query: "limit=100", wantLimit: maxLimit},
+		{query: "cursor=", wantLimit: defaultLimit, wantCursor: true},
+		{query: "limit=101", wantLimit: maxLimit},
+		{query: "limit=500&offset=10", wantLimit: maxLimit, wantOffset: 10},
+		{query: "cursor=&limit=101", wantField: "limit"},
+		{query: "limit=0", wantField: "limit"},
+		{query: "limit=-5", wantField: "limit"},
+		{query: "limit=ten", wantField: "limit"},
+		{query: "offset=-1", wantField: "offset"},
+		{query: "offset=x", wantField: "offset"},
+		{query: "cursor=garbage!", wantField: "cursor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/comments?"+tt.query, nil)
+
+			page, cursorMode, err := parsePage(c)
+			if tt.wantField != "" {
+				var verr *domain.ValidationError
+				if !errors.As(err, &verr) || len(verr.Fields) != 1 || verr.Fields[0].Field != tt.wantField {
+					t.Fatalf("error = %v, want validation error on %q", err, tt.wantField)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if page.Limit != tt.wantLimit || page.Offset != tt.wantOffset || cursorMode != tt.wantCursor {
+				t.Errorf("got limit=%d offset=%d cursor=%t, want limit=%d offset=%d cursor=%t",
+					page.Limit, page.Offset, cursorMode, tt.wantLimit, tt.wantOffset, tt.wantCursor)
+			}
+		})
+	}
+}
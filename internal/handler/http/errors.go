@@ -0,0 +1,81 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/wb-go/wbf/ginext"
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+	"github.com/yokitheyo/wb_level3_3/internal/dto"
+	"github.com/yokitheyo/wb_level3_3/internal/logging"
+)
+
+// errorMapping сопоставляет ошибку предметной области со статусом и кодом.
+var errorMapping = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{domain.ErrValidation, http.StatusBadRequest, "validation_error"},
+	{domain.ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+	{domain.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{domain.ErrNotFound, http.StatusNotFound, "not_found"},
+	{domain.ErrConflict, http.StatusConflict, "conflict"},
+	{domain.ErrParentDeleted, http.StatusUnprocessableEntity, "parent_deleted"},
+	{domain.ErrParentLocked, http.StatusUnprocessableEntity, "parent_locked"},
+}
+
+// writeError отвечает клиенту ошибкой. Ошибки предметной области отдаются
+// с соответствующим статусом и своим текстом; всё остальное считается
+// внутренним сбоем: логируется, а клиент получает 500 и fallback-сообщение.
+func writeError(c *ginext.Context, err error, fallback string) {
+	for _, m := range errorMapping {
+		if !errors.Is(err, m.err) {
+			continue
+		}
+
+		resp := &dto.ErrorResponse{Error: err.Error(), Code: m.code}
+
+		var verr *domain.ValidationError
+		if errors.As(err, &verr) {
+			resp.Fields = make([]dto.FieldErrorResponse, 0, len(verr.Fields))
+			for _, f := range verr.Fields {
+				resp.Fields = append(resp.Fields, dto.FieldErrorResponse{Field: f.Field, Message: f.Message})
+			}
+		}
+
+		c.JSON(m.status, resp)
+		return
+	}
+
+	logging.From(c.Request.Context()).Error().Err(err).Str("path", c.FullPath()).Msg(fallback)
+	c.JSON(http.StatusInternalServerError, &dto.ErrorResponse{Error: fallback, Code: "internal_error"})
+}
+
+// bindJSON разбирает тело запроса в dst. При ошибке отвечает клиенту сам
+// (413 для слишком большого тела, 400 для некорректного JSON) и возвращает false.
+func bindJSON(c *ginext.Context, dst interface{}) bool {
+	err := c.ShouldBindJSON(dst)
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, &dto.ErrorResponse{
+			Error: fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit),
+			Code:  "payload_too_large",
+		})
+		return false
+	}
+
+	logging.From(c.Request.Context()).Warn().Err(err).Msg("invalid request body")
+	writeBadRequest(c, "body", "invalid JSON")
+	return false
+}
+
+// writeBadRequest отвечает 400 с ошибкой валидации одного поля.
+func writeBadRequest(c *ginext.Context, field, message string) {
+	writeError(c, domain.NewValidationError(field, message), "")
+}
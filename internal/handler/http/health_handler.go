@@ -0,0 +1,82 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/wb-go/wbf/ginext"
+	"github.com/yokitheyo/wb_level3_3/internal/dto"
+	"github.com/yokitheyo/wb_level3_3/internal/logging"
+)
+
+// checkTimeout ограничивает время одной проверки готовности.
+const checkTimeout = 2 * time.Second
+
+// ReadinessCheck — одна проверка готовности. Check возвращает подробности
+// для ответа и ошибку, если зависимость недоступна. Ошибка Optional-проверки
+// попадает в ответ, но не делает сервис неготовым.
+type ReadinessCheck struct {
+	Name     string
+	Optional bool
+	Check    func(ctx context.Context) (interface{}, error)
+}
+
+// HealthHandler отвечает на пробы балансировщика и оркестратора:
+// /healthz — процесс жив, /readyz — сервис готов принимать трафик.
+type HealthHandler struct {
+	checks       []ReadinessCheck
+	shuttingDown atomic.Bool
+}
+
+func NewHealthHandler(checks ...ReadinessCheck) *HealthHandler {
+	return &HealthHandler{checks: checks}
+}
+
+func (h *HealthHandler) RegisterRoutes(engine *ginext.Engine) {
+	engine.GET("/healthz", h.Healthz)
+	engine.GET("/readyz", h.Readyz)
+}
+
+// SetShuttingDown переводит /readyz в состояние отказа, чтобы балансировщик
+// перестал слать запросы до остановки сервера.
+func (h *HealthHandler) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Healthz GET /healthz
+func (h *HealthHandler) Healthz(c *ginext.Context) {
+	c.JSON(http.StatusOK, &dto.HealthResponse{Status: "ok"})
+}
+
+// Readyz GET /readyz
+func (h *HealthHandler) Readyz(c *ginext.Context) {
+	if h.shuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable, &dto.HealthResponse{Status: "shutting_down"})
+		return
+	}
+
+	resp := &dto.HealthResponse{Status: "ready", Checks: make(map[string]*dto.CheckResult, len(h.checks))}
+	status := http.StatusOK
+
+	for _, check := range h.checks {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), checkTimeout)
+		details, err := check.Check(ctx)
+		cancel()
+
+		result := &dto.CheckResult{Status: "ok", Details: details}
+		if err != nil {
+			result.Status = "fail"
+			result.Error = err.Error()
+			if !check.Optional {
+				resp.Status = "not_ready"
+				status = http.StatusServiceUnavailable
+			}
+			logging.From(c.Request.Context()).Warn().Err(err).Str("check", check.Name).Msg("readiness check failed")
+		}
+		resp.Checks[check.Name] = result
+	}
+
+	c.JSON(status, resp)
+}
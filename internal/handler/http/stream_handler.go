@@ -0,0 +1,185 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wb-go/wbf/ginext"
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+	"github.com/yokitheyo/wb_level3_3/internal/dto"
+	"github.com/yokitheyo/wb_level3_3/internal/events"
+	"github.com/yokitheyo/wb_level3_3/internal/logging"
+)
+
+// streamRetry — через сколько браузер переподключается после обрыва потока.
+const streamRetry = 3 * time.Second
+
+// StreamHandler отдаёт изменения комментариев как Server-Sent Events.
+type StreamHandler struct {
+	service   domain.CommentService
+	bus       *events.Bus
+	heartbeat time.Duration
+
+	maxPerClient int
+	mu           sync.Mutex
+	open         map[string]int // открытые потоки по клиентам
+}
+
+// NewStreamHandler создаёт StreamHandler. heartbeat — период комментариев-
+// пингов, не дающих прокси закрыть простаивающее соединение; maxPerClient —
+// предел одновременных потоков одного клиента (0 — без предела).
+func NewStreamHandler(service domain.CommentService, bus *events.Bus, heartbeat time.Duration, maxPerClient int) *StreamHandler {
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+	return &StreamHandler{
+		service:      service,
+		bus:          bus,
+		heartbeat:    heartbeat,
+		maxPerClient: maxPerClient,
+		open:         make(map[string]int),
+	}
+}
+
+func (h *StreamHandler) RegisterRoutes(engine *ginext.Engine) {
+	engine.GET("/comments/stream", h.Stream)
+}
+
+// Stream GET /comments/stream?thread={rootId}
+//
+// Без thread поток содержит события всех тредов. Клиенту, у которого уже
+// открыто maxPerClient потоков, отвечает 429. После переподключения
+// браузер присылает Last-Event-ID, и пропущенные события досылаются из
+// буфера; если их там уже нет, первым приходит событие reset — клиенту
+// нужно перечитать тред. ID событий у каждого экземпляра свои, поэтому
+// при переподключении к другому экземпляру клиент тоже получит reset.
+func (h *StreamHandler) Stream(c *ginext.Context) {
+	var thread int64
+	if s := c.Query("thread"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id <= 0 {
+			writeBadRequest(c, "thread", "must be a positive integer")
+			return
+		}
+		root, err := h.service.GetComment(c, id, 0)
+		if err != nil {
+			writeError(c, err, "failed to open stream")
+			return
+		}
+		if root.ParentID != nil {
+			writeBadRequest(c, "thread", "must be the id of a top-level comment")
+			return
+		}
+		thread = id
+	}
+
+	client := streamClient(c)
+	if !h.acquire(client) {
+		c.JSON(http.StatusTooManyRequests, &dto.ErrorResponse{Error: "too many open streams", Code: "rate_limited"})
+		return
+	}
+	defer h.release(client)
+
+	sub, replay, complete, err := h.bus.Subscribe(thread, c.GetHeader("Last-Event-ID"))
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, &dto.ErrorResponse{Error: "event stream unavailable", Code: "unavailable"})
+		return
+	}
+	defer sub.Close()
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	if !complete {
+		io.WriteString(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, e := range replay {
+		if err := writeEvent(w, e); err != nil {
+			return
+		}
+	}
+	w.Flush()
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				// Отстали от потока или сервер останавливается: браузер
+				// переподключится и дочитает пропущенное по Last-Event-ID.
+				logging.From(ctx).Debug().Int64("thread", thread).Msg("event stream closed by server")
+				return
+			}
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		w.Flush()
+	}
+}
+
+// streamClient возвращает ключ клиента для предела потоков — как у
+// ограничения частоты: пользователь, если он известен, иначе IP. Маршрут не
+// аутентифицирован, так что на деле ключ — IP, и он верен, только если
+// server.trusted_proxies настроен.
+func streamClient(c *ginext.Context) string {
+	if p, ok := domain.PrincipalFromContext(c.Request.Context()); ok {
+		return "user:" + p.Subject
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// acquire занимает место под поток клиента; false — предел исчерпан.
+func (h *StreamHandler) acquire(client string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.maxPerClient > 0 && h.open[client] >= h.maxPerClient {
+		return false
+	}
+	h.open[client]++
+	return true
+}
+
+func (h *StreamHandler) release(client string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.open[client]--; h.open[client] <= 0 {
+		delete(h.open, client)
+	}
+}
+
+func writeEvent(w io.Writer, e events.Event) error {
+	data, err := json.Marshal(&dto.CommentEventResponse{
+		Type:      string(e.Type),
+		ThreadID:  e.ThreadID,
+		CommentID: e.CommentID,
+		ParentID:  e.ParentID,
+		Comment:   mapToCommentResponse(e.Comment),
+		Mode:      string(e.Mode),
+		Affected:  e.Affected,
+		At:        e.At,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
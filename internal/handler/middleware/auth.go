@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wb-go/wbf/ginext"
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+	"github.com/yokitheyo/wb_level3_3/internal/dto"
+	"github.com/yokitheyo/wb_level3_3/internal/logging"
+)
+
+// APIKey — статический ключ доступа и учётная запись, от имени которой он действует.
+type APIKey struct {
+	Key     string
+	Subject string
+	Roles   []string
+}
+
+// tokenClaims — ожидаемые claims JWT: sub обязателен, roles — необязательный список ролей.
+type tokenClaims struct {
+	Roles []string `json:"roles"`
+	jwt.RegisteredClaims
+}
+
+var errNoCredentials = errors.New("missing credentials")
+
+// AuthMiddleware требует аутентификации: статический API-ключ в заголовке
+// X-API-Key или Authorization: Bearer, либо JWT с HMAC-подписью ключом
+// jwtSecret. Найденный Principal кладётся в контекст запроса.
+func AuthMiddleware(jwtSecret string, apiKeys []APIKey) ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		principal, err := authenticate(c.Request, jwtSecret, apiKeys)
+		if err != nil {
+			logging.From(c.Request.Context()).Warn().Err(err).Str("path", c.Request.URL.Path).Msg("authentication failed")
+			c.Header("WWW-Authenticate", `Bearer realm="comments"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, &dto.ErrorResponse{
+				Error: "authentication required",
+				Code:  "unauthorized",
+			})
+			return
+		}
+
+		c.Request = c.Request.WithContext(domain.WithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	}
+}
+
+func authenticate(r *http.Request, jwtSecret string, apiKeys []APIKey) (*domain.Principal, error) {
+	credential := strings.TrimSpace(r.Header.Get("X-API-Key"))
+	if credential == "" {
+		auth := r.Header.Get("Authorization")
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			credential = strings.TrimSpace(token)
+		}
+	}
+	if credential == "" {
+		return nil, errNoCredentials
+	}
+
+	for _, k := range apiKeys {
+		if k.Key != "" && subtle.ConstantTimeCompare([]byte(k.Key), []byte(credential)) == 1 {
+			return &domain.Principal{Subject: k.Subject, Roles: k.Roles}, nil
+		}
+	}
+
+	if jwtSecret == "" {
+		return nil, errors.New("unknown API key")
+	}
+
+	claims := &tokenClaims{}
+	_, err := jwt.ParseWithClaims(credential, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(jwtSecret), nil
+	}, jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	return &domain.Principal{Subject: claims.Subject, Roles: claims.Roles}, nil
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/wb-go/wbf/ginext"
+)
+
+// BodyLimitMiddleware ограничивает размер тела запроса. Чтение сверх лимита
+// завершается ошибкой *http.MaxBytesError, которую обрабатывает хендлер.
+func BodyLimitMiddleware(maxBytes int64) ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		if maxBytes > 0 && c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		}
+		c.Next()
+	}
+}
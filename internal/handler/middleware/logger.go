@@ -4,20 +4,25 @@ import (
 	"time"
 
 	"github.com/wb-go/wbf/ginext"
-	"github.com/wb-go/wbf/zlog"
+
+	"github.com/yokitheyo/wb_level3_3/internal/logging"
 )
 
+// LoggerMiddleware пишет строку о каждом запросе логгером запроса, так что
+// она несёт request_id. Должен стоять после RequestIDMiddleware.
 func LoggerMiddleware() ginext.HandlerFunc {
 	return func(c *ginext.Context) {
 		start := time.Now()
 		c.Next()
 		duration := time.Since(start)
 
-		zlog.Logger.Info().
+		logging.From(c.Request.Context()).Info().
 			Str("method", c.Request.Method).
 			Str("path", c.Request.URL.Path).
+			Str("route", c.FullPath()).
 			Int("status", c.Writer.Status()).
 			Dur("duration", duration).
+			Str("client_ip", c.ClientIP()).
 			Msg("HTTP request")
 	}
 }
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"strings"
+	"time"
+
+	"github.com/wb-go/wbf/ginext"
+	"github.com/yokitheyo/wb_level3_3/internal/metrics"
+)
+
+// MetricsMiddleware учитывает каждый запрос в метриках по шаблону маршрута;
+// запросы мимо маршрутов сводятся в один ярлык, чтобы сканеры не плодили серии.
+// Ответы text/event-stream учитываются как потоки, вне гистограммы задержек.
+func MetricsMiddleware() ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		if strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "text/event-stream") {
+			metrics.ObserveStream(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+			return
+		}
+		metrics.ObserveHTTP(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/wb-go/wbf/ginext"
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+	"github.com/yokitheyo/wb_level3_3/internal/dto"
+	"github.com/yokitheyo/wb_level3_3/internal/infrastructure/ratelimit"
+	"github.com/yokitheyo/wb_level3_3/internal/logging"
+)
+
+// RateLimitMiddleware ограничивает частоту запросов: ключ — пользователь из
+// контекста (если маршрут аутентифицирован), иначе IP клиента. При
+// превышении отвечает 429 с Retry-After. Если лимитер недоступен, запрос
+// пропускается: отказ Redis не должен останавливать сервис.
+func RateLimitMiddleware(limiter ratelimit.Limiter) ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		key := "ip:" + c.ClientIP()
+		if p, ok := domain.PrincipalFromContext(c.Request.Context()); ok {
+			key = "user:" + p.Subject
+		}
+
+		allowed, wait, err := limiter.Allow(c.Request.Context(), key)
+		if err != nil {
+			logging.From(c.Request.Context()).Error().Err(err).Str("path", c.FullPath()).Msg("rate limiter failed, allowing request")
+			c.Next()
+			return
+		}
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, &dto.ErrorResponse{
+				Error: "rate limit exceeded",
+				Code:  "rate_limited",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
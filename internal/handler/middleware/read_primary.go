@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/wb-go/wbf/ginext"
+	"github.com/yokitheyo/wb_level3_3/internal/infrastructure/database"
+)
+
+const (
+	// ReadPrimaryHeader явно просит читать с master.
+	ReadPrimaryHeader = "X-Read-Primary"
+	// readPrimaryCookie ставится после изменяющего запроса, чтобы следующие
+	// чтения клиента не ушли на отстающую реплику.
+	readPrimaryCookie = "read_primary"
+)
+
+// ReadPrimaryMiddleware направляет чтения запроса на master, если клиент
+// прислал заголовок X-Read-Primary или недавно что-то записывал. После
+// изменяющего запроса клиенту ставится cookie на stickySec секунд — время,
+// за которое реплики должны догнать master.
+func ReadPrimaryMiddleware(stickySec int) ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		primary := isTruthy(c.GetHeader(ReadPrimaryHeader))
+		if v, err := c.Cookie(readPrimaryCookie); err == nil && v == "1" {
+			primary = true
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if stickySec > 0 {
+				c.SetSameSite(http.SameSiteLaxMode)
+				c.SetCookie(readPrimaryCookie, "1", stickySec, "/", "", false, true)
+			}
+		}
+
+		if primary {
+			c.Request = c.Request.WithContext(database.WithPrimary(c.Request.Context()))
+		}
+		c.Next()
+	}
+}
+
+func isTruthy(v string) bool {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/yokitheyo/wb_level3_3/internal/logging"
+)
+
+// RequestIDHeader — заголовок с идентификатором запроса.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает принятый от клиента идентификатор, чтобы
+// в логи не попадали произвольные данные.
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware берёт X-Request-ID из запроса или генерирует новый,
+// возвращает его в ответе и кладёт в контекст запроса дочерний логгер с
+// полями request_id и, если запрос трассируется, trace_id.
+func RequestIDMiddleware() ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+
+		ctx := c.Request.Context()
+		fields := zlog.Logger.With().Str("request_id", id)
+		if span := trace.SpanFromContext(ctx); span.SpanContext().IsValid() {
+			fields = fields.Str("trace_id", span.SpanContext().TraceID().String())
+			span.SetAttributes(attribute.String("http.request_id", id))
+		}
+
+		c.Request = c.Request.WithContext(logging.WithLogger(ctx, fields.Logger()))
+		c.Next()
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
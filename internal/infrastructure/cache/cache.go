@@ -0,0 +1,27 @@
+// Package cache — хранилища для кэша чтения: значения с TTL и счётчики
+// версий, через которые кэш инвалидируется.
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrUnavailable возвращается, пока хранилище считается недоступным после
+// сбоя; вызывающий код должен идти в источник данных напрямую.
+var ErrUnavailable = errors.New("cache unavailable")
+
+// Cache хранит сериализованные значения и счётчики версий. Ключи
+// инвалидируются не удалением, а увеличением версии, входящей в ключ: так
+// чтение, начатое до изменения, не может записать устаревшее значение под
+// новым ключом.
+type Cache interface {
+	// Get возвращает значение ключа; false — промах.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Versions возвращает текущие значения счётчиков, 0 — для отсутствующих.
+	Versions(ctx context.Context, keys ...string) ([]int64, error)
+	// Bump увеличивает счётчики на единицу.
+	Bump(ctx context.Context, keys ...string) error
+}
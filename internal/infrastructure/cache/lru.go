@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats — счётчики обращений к LRU.
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+}
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// LRUCache — кэш в памяти процесса, ограниченный числом записей. При
+// переполнении вытесняются давно не читанные записи, просроченные по TTL
+// считаются промахом.
+//
+// Версии берутся из общего возрастающего счётчика. Таблица версий тоже
+// ограничена: при переполнении она очищается вместе со значениями, а
+// отсутствующие версии с этого момента равны floor — значению счётчика на
+// момент очистки. Поэтому версия ключа никогда не уменьшается и старые
+// значения не могут снова стать видимыми.
+type LRUCache struct {
+	maxEntries int
+
+	mu       sync.Mutex
+	ll       *list.List
+	items    map[string]*list.Element
+	versions map[string]int64
+	seq      int64
+	floor    int64
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+func NewLRUCache(maxEntries int) *LRUCache {
+	return &LRUCache{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		versions:   make(map[string]int64),
+	}
+}
+
+func (c *LRUCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false, nil
+	}
+	e := el.Value.(*lruEntry)
+	if time.Now().After(e.expiresAt) {
+		c.removeElement(el)
+		c.misses.Add(1)
+		return nil, false, nil
+	}
+
+	c.ll.MoveToFront(el)
+	c.hits.Add(1)
+	return e.value, true, nil
+}
+
+func (c *LRUCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*lruEntry)
+		e.value, e.expiresAt = value, expiresAt
+		c.ll.MoveToFront(el)
+		return nil
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.maxEntries > 0 && c.ll.Len() > c.maxEntries {
+		c.removeElement(c.ll.Back())
+		c.evictions.Add(1)
+	}
+	return nil
+}
+
+func (c *LRUCache) Versions(_ context.Context, keys ...string) ([]int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make([]int64, len(keys))
+	for i, k := range keys {
+		v, ok := c.versions[k]
+		if !ok {
+			v = c.floor
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func (c *LRUCache) Bump(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.maxEntries > 0 && len(c.versions)+len(keys) > c.maxEntries {
+		c.versions = make(map[string]int64)
+		c.items = make(map[string]*list.Element)
+		c.ll.Init()
+		c.floor = c.seq
+	}
+	for _, k := range keys {
+		c.seq++
+		c.versions[k] = c.seq
+	}
+	return nil
+}
+
+// Stats возвращает текущие счётчики.
+func (c *LRUCache) Stats() Stats {
+	c.mu.Lock()
+	entries := c.ll.Len()
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   entries,
+	}
+}
+
+func (c *LRUCache) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*lruEntry).key)
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/wb-go/wbf/redis"
+	"github.com/wb-go/wbf/zlog"
+)
+
+const (
+	// versionTTL заметно больше TTL значений: счётчик не должен истечь и
+	// начаться заново, пока живы значения со старыми версиями.
+	versionTTL = 7 * 24 * time.Hour
+	// cooldown — сколько после сбоя не обращаться к Redis, чтобы запросы
+	// не ждали таймаутов подключения.
+	cooldown = 5 * time.Second
+)
+
+// RedisCache хранит кэш в Redis. После ошибки Redis на время cooldown
+// считается недоступным и все вызовы сразу возвращают ErrUnavailable.
+type RedisCache struct {
+	client    *redis.Client
+	prefix    string
+	downUntil atomic.Int64
+}
+
+func NewRedisCache(client *redis.Client, prefix string) *RedisCache {
+	return &RedisCache{client: client, prefix: prefix}
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if err := c.available(); err != nil {
+		return nil, false, err
+	}
+	data, err := c.client.Client.Get(ctx, c.prefix+key).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, c.fail(err)
+	}
+	return data, true, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := c.available(); err != nil {
+		return err
+	}
+	if err := c.client.Client.Set(ctx, c.prefix+key, value, ttl).Err(); err != nil {
+		return c.fail(err)
+	}
+	return nil
+}
+
+func (c *RedisCache) Versions(ctx context.Context, keys ...string) ([]int64, error) {
+	if err := c.available(); err != nil {
+		return nil, err
+	}
+	full := make([]string, len(keys))
+	for i, k := range keys {
+		full[i] = c.prefix + k
+	}
+	vals, err := c.client.MGet(ctx, full...).Result()
+	if err != nil {
+		return nil, c.fail(err)
+	}
+
+	out := make([]int64, len(vals))
+	for i, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		out[i], _ = strconv.ParseInt(s, 10, 64)
+	}
+	return out, nil
+}
+
+func (c *RedisCache) Bump(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := c.available(); err != nil {
+		return err
+	}
+	_, err := c.client.Pipelined(ctx, func(p goredis.Pipeliner) error {
+		for _, k := range keys {
+			p.Incr(ctx, c.prefix+k)
+			p.Expire(ctx, c.prefix+k, versionTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		return c.fail(err)
+	}
+	return nil
+}
+
+func (c *RedisCache) available() error {
+	if time.Now().UnixNano() < c.downUntil.Load() {
+		return ErrUnavailable
+	}
+	return nil
+}
+
+func (c *RedisCache) fail(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	c.downUntil.Store(time.Now().Add(cooldown).UnixNano())
+	zlog.Logger.Warn().Err(err).Dur("cooldown", cooldown).Msg("redis cache unavailable")
+	return err
+}
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 
 	"github.com/pressly/goose/v3"
@@ -24,3 +26,22 @@ func RunMigrations(db *dbpg.DB, migrationsDir string) error {
 	zlog.Logger.Info().Msg("migrations applied successfully")
 	return nil
 }
+
+// MigrationStatus возвращает версию схемы в базе и последнюю версию среди
+// миграций в migrationsDir.
+func MigrationStatus(ctx context.Context, db *sql.DB, migrationsDir string) (current, latest int64, err error) {
+	current, err = goose.GetDBVersionContext(ctx, db)
+	if err != nil {
+		return 0, 0, fmt.Errorf("read schema version: %w", err)
+	}
+
+	migrations, err := goose.CollectMigrations(migrationsDir, 0, goose.MaxVersion)
+	if err != nil {
+		return current, 0, fmt.Errorf("collect migrations: %w", err)
+	}
+	last, err := migrations.Last()
+	if err != nil {
+		return current, 0, fmt.Errorf("collect migrations: %w", err)
+	}
+	return current, last.Version, nil
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+type primaryKey struct{}
+
+// WithPrimary помечает контекст: чтения в нём должны идти на master, например
+// чтобы пользователь сразу увидел только что записанный комментарий.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// PrimaryRequested сообщает, помечен ли контекст WithPrimary.
+func PrimaryRequested(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryKey{}).(bool)
+	return v
+}
+
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// ReplicaStatus — состояние реплики по последней проверке.
+type ReplicaStatus struct {
+	Index   int  `json:"index"`
+	Healthy bool `json:"healthy"`
+}
+
+// ReplicaSet распределяет чтения по репликам по кругу, пропуская те, что не
+// прошли последнюю проверку. Если здоровых реплик нет или запрошено чтение
+// с master, отдаёт master.
+type ReplicaSet struct {
+	master   *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+func NewReplicaSet(master *sql.DB, replicas []*sql.DB) *ReplicaSet {
+	s := &ReplicaSet{master: master}
+	for _, db := range replicas {
+		s.replicas = append(s.replicas, &replica{db: db})
+	}
+	return s
+}
+
+// Reader возвращает подключение для чтения вне транзакции.
+func (s *ReplicaSet) Reader(ctx context.Context) *sql.DB {
+	if len(s.replicas) == 0 || PrimaryRequested(ctx) {
+		return s.master
+	}
+
+	start := s.next.Add(1)
+	for i := range s.replicas {
+		r := s.replicas[(start+uint64(i))%uint64(len(s.replicas))]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+	return s.master
+}
+
+// Check пингует все реплики и обновляет их состояние.
+func (s *ReplicaSet) Check(ctx context.Context, timeout time.Duration) {
+	for i, r := range s.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := r.db.PingContext(pingCtx)
+		cancel()
+
+		healthy := err == nil
+		if r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				zlog.Logger.Info().Int("replica", i).Msg("replica is healthy, routing reads to it")
+			} else {
+				zlog.Logger.Warn().Err(err).Int("replica", i).Msg("replica is unhealthy, excluded from reads")
+			}
+		}
+	}
+}
+
+// Run проверяет реплики каждые interval, пока не отменён ctx.
+func (s *ReplicaSet) Run(ctx context.Context, interval time.Duration) {
+	if len(s.replicas) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Check(ctx, interval)
+		}
+	}
+}
+
+// Status возвращает состояние реплик по последней проверке.
+func (s *ReplicaSet) Status() []ReplicaStatus {
+	out := make([]ReplicaStatus, 0, len(s.replicas))
+	for i, r := range s.replicas {
+		out = append(out, ReplicaStatus{Index: i, Healthy: r.healthy.Load()})
+	}
+	return out
+}
@@ -0,0 +1,187 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+	"github.com/yokitheyo/wb_level3_3/internal/events"
+	"github.com/yokitheyo/wb_level3_3/internal/infrastructure/database"
+)
+
+const (
+	minReconnect = 500 * time.Millisecond
+	maxReconnect = 30 * time.Second
+	// pingInterval — как часто проверять простаивающее соединение: без
+	// трафика обрыв иначе обнаружится только по TCP keepalive.
+	pingInterval = 60 * time.Second
+	fetchTimeout = 2 * time.Second
+	// fetchWorkers и fetchQueue — обработчики уведомлений и их очереди.
+	// События одного треда попадают к одному обработчику и публикуются по
+	// порядку; медленное перечитывание задерживает только его очередь.
+	fetchWorkers = 4
+	fetchQueue   = 256
+)
+
+// Listener слушает канал уведомлений и публикует полученные события в
+// локальную шину. Для созданных, изменённых и восстановленных комментариев
+// он перечитывает комментарий с master — в отдельных обработчиках, чтобы
+// чтение не задерживало приём уведомлений. После переподключения и при
+// переполнении очереди обработчика шина сбрасывается: события потеряны, и
+// клиенты должны перечитать треды.
+type Listener struct {
+	dsn       string
+	channel   string
+	repo      domain.CommentRepository
+	bus       *events.Bus
+	connected atomic.Bool
+}
+
+func NewListener(dsn, channel string, repo domain.CommentRepository, bus *events.Bus) *Listener {
+	return &Listener{dsn: dsn, channel: channel, repo: repo, bus: bus}
+}
+
+// Connected сообщает, установлено ли сейчас соединение LISTEN.
+func (l *Listener) Connected() bool {
+	return l.connected.Load()
+}
+
+// Run слушает канал до отмены ctx. Блокирует вызывающего.
+func (l *Listener) Run(ctx context.Context) {
+	queues := make([]chan events.Event, fetchWorkers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan events.Event, fetchQueue)
+		wg.Add(1)
+		go func(queue <-chan events.Event) {
+			defer wg.Done()
+			for e := range queue {
+				l.deliver(ctx, e)
+			}
+		}(queues[i])
+	}
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+		wg.Wait()
+	}()
+
+	listener := pq.NewListener(l.dsn, minReconnect, maxReconnect, l.onEvent)
+	defer listener.Close()
+
+	// Listen блокируется, пока соединение не установлено; Close по отмене
+	// ctx прерывает его.
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+	if !l.listen(ctx, listener) {
+		return
+	}
+	zlog.Logger.Info().Str("channel", l.channel).Msg("notify: listening")
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			zlog.Logger.Info().Msg("notify: listener stopped")
+			return
+		case n, ok := <-listener.Notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				// pq присылает nil после переподключения.
+				l.bus.Reset()
+				continue
+			}
+			l.enqueue(queues, n.Extra)
+		case <-ticker.C:
+			go listener.Ping()
+		}
+	}
+}
+
+// listen подписывается на канал, повторяя попытки с растущей паузой, пока
+// сервер отклоняет LISTEN. false — ctx отменён.
+func (l *Listener) listen(ctx context.Context, listener *pq.Listener) bool {
+	backoff := minReconnect
+	for {
+		err := listener.Listen(l.channel)
+		if err == nil || errors.Is(err, pq.ErrChannelAlreadyOpen) {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		zlog.Logger.Error().Err(err).Str("channel", l.channel).Dur("retry_in", backoff).Msg("notify: LISTEN failed")
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxReconnect)
+	}
+}
+
+// enqueue передаёт событие обработчику его треда. Если очередь полна,
+// событие теряется и шина сбрасывается, как после обрыва соединения.
+func (l *Listener) enqueue(queues []chan events.Event, payload string) {
+	var m message
+	if err := json.Unmarshal([]byte(payload), &m); err != nil {
+		zlog.Logger.Warn().Err(err).Str("payload", payload).Msg("notify: malformed notification")
+		return
+	}
+	e := m.event()
+
+	select {
+	case queues[uint64(e.ThreadID)%uint64(len(queues))] <- e:
+	default:
+		zlog.Logger.Warn().Int64("thread_id", e.ThreadID).Msg("notify: event queue full, resetting event bus")
+		l.bus.Reset()
+	}
+}
+
+// deliver дополняет событие актуальным комментарием и публикует его.
+func (l *Listener) deliver(ctx context.Context, e events.Event) {
+	if e.Type == events.CommentCreated || e.Type == events.CommentEdited || e.Type == events.CommentRestored {
+		fetchCtx, cancel := context.WithTimeout(database.WithPrimary(ctx), fetchTimeout)
+		c, err := l.repo.FindByID(fetchCtx, e.CommentID)
+		cancel()
+		switch {
+		case err == nil:
+			c.Tombstone()
+			e.Comment = c
+		case errors.Is(err, domain.ErrNotFound):
+			// Комментарий успели удалить; событие об этом придёт следом.
+		default:
+			zlog.Logger.Error().Err(err).Int64("comment_id", e.CommentID).Msg("notify: refetching comment failed")
+		}
+	}
+
+	l.bus.Publish(ctx, e)
+}
+
+func (l *Listener) onEvent(ev pq.ListenerEventType, err error) {
+	switch ev {
+	case pq.ListenerEventConnected:
+		l.connected.Store(true)
+	case pq.ListenerEventReconnected:
+		l.connected.Store(true)
+		zlog.Logger.Info().Str("channel", l.channel).Msg("notify: listener reconnected")
+	case pq.ListenerEventDisconnected:
+		l.connected.Store(false)
+		zlog.Logger.Warn().Err(err).Str("channel", l.channel).Msg("notify: listener disconnected, reconnecting")
+	case pq.ListenerEventConnectionAttemptFailed:
+		zlog.Logger.Warn().Err(err).Msg("notify: listener connection attempt failed")
+	}
+}
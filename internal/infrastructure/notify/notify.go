@@ -0,0 +1,51 @@
+// Package notify разносит события комментариев между экземплярами сервиса
+// через LISTEN/NOTIFY в Postgres: Publisher отправляет pg_notify, а Listener
+// на каждом экземпляре (включая отправивший) передаёт их в локальную шину.
+package notify
+
+import (
+	"time"
+
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+	"github.com/yokitheyo/wb_level3_3/internal/events"
+)
+
+// maxPayload — предел размера payload у NOTIFY в Postgres (8000 байт).
+// Текст комментария в уведомление не кладётся: получатель перечитывает
+// комментарий по id, поэтому payload всегда много меньше предела.
+const maxPayload = 8000
+
+// message — payload уведомления.
+type message struct {
+	Type      events.Type       `json:"type"`
+	ThreadID  int64             `json:"thread_id"`
+	CommentID int64             `json:"comment_id"`
+	ParentID  *int64            `json:"parent_id,omitempty"`
+	Mode      domain.DeleteMode `json:"mode,omitempty"`
+	Affected  int64             `json:"affected,omitempty"`
+	At        time.Time         `json:"at"`
+}
+
+func newMessage(e events.Event) message {
+	return message{
+		Type:      e.Type,
+		ThreadID:  e.ThreadID,
+		CommentID: e.CommentID,
+		ParentID:  e.ParentID,
+		Mode:      e.Mode,
+		Affected:  e.Affected,
+		At:        e.At,
+	}
+}
+
+func (m message) event() events.Event {
+	return events.Event{
+		Type:      m.Type,
+		ThreadID:  m.ThreadID,
+		CommentID: m.CommentID,
+		ParentID:  m.ParentID,
+		Mode:      m.Mode,
+		Affected:  m.Affected,
+		At:        m.At,
+	}
+}
@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/yokitheyo/wb_level3_3/internal/events"
+	"github.com/yokitheyo/wb_level3_3/internal/logging"
+)
+
+// publishTimeout ограничивает отправку уведомления: событие публикуется уже
+// после фиксации изменений и не должно задерживать ответ.
+const publishTimeout = 2 * time.Second
+
+// Publisher публикует события через pg_notify. Если отправить уведомление не
+// удалось, событие уходит в local, чтобы его получили хотя бы подписчики
+// этого экземпляра.
+type Publisher struct {
+	db      *sql.DB
+	channel string
+	local   events.Publisher
+}
+
+func NewPublisher(db *sql.DB, channel string, local events.Publisher) *Publisher {
+	return &Publisher{db: db, channel: channel, local: local}
+}
+
+func (p *Publisher) Publish(ctx context.Context, e events.Event) {
+	if err := p.notify(ctx, e); err != nil {
+		logging.From(ctx).Error().Err(err).Str("channel", p.channel).Msg("notify: pg_notify failed, delivering locally")
+		p.local.Publish(ctx, e)
+	}
+}
+
+func (p *Publisher) notify(ctx context.Context, e events.Event) error {
+	payload, err := json.Marshal(newMessage(e))
+	if err != nil {
+		return err
+	}
+	if len(payload) >= maxPayload {
+		return fmt.Errorf("payload of %d bytes exceeds NOTIFY limit", len(payload))
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), publishTimeout)
+	defer cancel()
+	_, err = p.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, p.channel, string(payload))
+	return err
+}
@@ -0,0 +1,27 @@
+// Package ratelimit реализует ограничение частоты запросов алгоритмом
+// token bucket: в памяти процесса или общим для всех экземпляров в Redis.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Rate — параметры корзины: PerMinute токенов пополняется в минуту,
+// Burst — ёмкость корзины (сколько запросов можно сделать подряд).
+type Rate struct {
+	PerMinute int
+	Burst     int
+}
+
+// perMilli возвращает скорость пополнения в токенах за миллисекунду.
+func (r Rate) perMilli() float64 {
+	return float64(r.PerMinute) / float64(time.Minute/time.Millisecond)
+}
+
+// Limiter расходует токены корзины, выделенной ключу.
+type Limiter interface {
+	// Allow забирает токен у корзины key. Если токенов нет, возвращает false
+	// и время, через которое появится следующий.
+	Allow(ctx context.Context, key string) (bool, time.Duration, error)
+}
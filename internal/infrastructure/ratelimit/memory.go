@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval — как часто MemoryLimiter выбрасывает полностью
+// восстановившиеся корзины, чтобы карта не росла бесконечно.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryLimiter хранит корзины в памяти процесса.
+type MemoryLimiter struct {
+	rate Rate
+	now  func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryLimiter(rate Rate) *MemoryLimiter {
+	return &MemoryLimiter{
+		rate:      rate,
+		now:       time.Now,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *MemoryLimiter) Allow(_ context.Context, key string) (bool, time.Duration, error) {
+	now := l.now()
+	perMilli := l.rate.perMilli()
+	burst := float64(l.rate.Burst)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now, perMilli, burst)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	elapsed := float64(now.Sub(b.last) / time.Millisecond)
+	b.tokens = math.Min(burst, b.tokens+elapsed*perMilli)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+
+	wait := time.Duration(math.Ceil((1-b.tokens)/perMilli)) * time.Millisecond
+	return false, wait, nil
+}
+
+// sweep удаляет корзины, которые к моменту now успели наполниться:
+// они неотличимы от новых.
+func (l *MemoryLimiter) sweep(now time.Time, perMilli, burst float64) {
+	for key, b := range l.buckets {
+		elapsed := float64(now.Sub(b.last) / time.Millisecond)
+		if b.tokens+elapsed*perMilli >= burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
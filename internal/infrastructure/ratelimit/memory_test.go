@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock — часы, которые двигаются только вручную.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(rate Rate) (*MemoryLimiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewMemoryLimiter(rate)
+	l.now = clock.now
+	l.lastSweep = clock.t
+	return l, clock
+}
+
+func TestMemoryLimiterAllow(t *testing.T) {
+	// 60 в минуту — один токен в секунду.
+	rate := Rate{PerMinute: 60, Burst: 2}
+
+	type step struct {
+		advance  time.Duration
+		wantOK   bool
+		wantWait time.Duration
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"burst then wait", []step{
+			{0, true, 0},
+			{0, true, 0},
+			{0, false, time.Second},
+		}},
+		{"wait shrinks with elapsed time", []step{
+			{0, true, 0},
+			{0, true, 0},
+			{400 * time.Millisecond, false, 600 * time.Millisecond},
+		}},
+		{"refill after wait", []step{
+			{0, true, 0},
+			{0, true, 0},
+			{0, false, time.Second},
+			{time.Second, true, 0},
+			{0, false, time.Second},
+		}},
+		{"refill capped at burst", []step{
+			{0, true, 0},
+			{0, true, 0},
+			{time.Hour, true, 0},
+			{0, true, 0},
+			{0, false, time.Second},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, clock := newTestLimiter(rate)
+			for i, s := range tt.steps {
+				clock.advance(s.advance)
+				ok, wait, err := l.Allow(context.Background(), "k")
+				if err != nil {
+					t.Fatalf("step %d: unexpected error: %v", i, err)
+				}
+				if ok != s.wantOK || wait != s.wantWait {
+					t.Errorf("step %d: got (%v, %v), want (%v, %v)", i, ok, wait, s.wantOK, s.wantWait)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryLimiterKeysIndependent(t *testing.T) {
+	l, _ := newTestLimiter(Rate{PerMinute: 60, Burst: 1})
+	ctx := context.Background()
+
+	if ok, _, _ := l.Allow(ctx, "a"); !ok {
+		t.Fatal("first request for a denied")
+	}
+	if ok, _, _ := l.Allow(ctx, "a"); ok {
+		t.Fatal("second request for a allowed")
+	}
+	if ok, _, _ := l.Allow(ctx, "b"); !ok {
+		t.Fatal("request for b denied after a was exhausted")
+	}
+}
+
+func TestMemoryLimiterSweep(t *testing.T) {
+	l, clock := newTestLimiter(Rate{PerMinute: 60, Burst: 1})
+	ctx := context.Background()
+
+	l.Allow(ctx, "a")
+	clock.advance(sweepInterval)
+	l.Allow(ctx, "b")
+
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("refilled bucket a was not swept")
+	}
+	if _, ok := l.buckets["b"]; !ok {
+		t.Error("bucket b missing")
+	}
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/wb-go/wbf/redis"
+)
+
+// tokenBucketScript атомарно пополняет корзину и забирает из неё токен.
+// Возвращает {1, 0}, если запрос разрешён, иначе {0, ожидание в мс}.
+// Время берётся из часов Redis, а не экземпляров сервиса: расхождение их
+// часов иначе сдвигало бы пополнение общей корзины.
+var tokenBucketScript = goredis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  wait = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate))
+return {allowed, wait}
+`)
+
+// RedisLimiter хранит корзины в Redis, поэтому лимит общий для всех
+// экземпляров сервиса.
+type RedisLimiter struct {
+	client *redis.Client
+	prefix string
+	rate   Rate
+}
+
+func NewRedisLimiter(client *redis.Client, prefix string, rate Rate) *RedisLimiter {
+	return &RedisLimiter{client: client, prefix: prefix, rate: rate}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	res, err := tokenBucketScript.Run(ctx, l.client.Client,
+		[]string{l.prefix + key},
+		l.rate.perMilli(), l.rate.Burst,
+	).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(res) != 2 {
+		return false, 0, fmt.Errorf("ratelimit: unexpected script reply %v", res)
+	}
+
+	if res[0] == 1 {
+		return true, 0, nil
+	}
+	wait := time.Duration(math.Max(float64(res[1]), 1)) * time.Millisecond
+	return false, wait, nil
+}
@@ -7,7 +7,7 @@ import (
 )
 
 type FullTextSearcher interface {
-	SearchComments(ctx context.Context, query string, limit, offset int) ([]*domain.Comment, error)
+	SearchComments(ctx context.Context, query string, page domain.Page) ([]*domain.SearchHit, error)
 }
 
 type PostgresFullText struct {
@@ -19,6 +19,6 @@ func NewPostgresFullText(repo domain.CommentRepository) *PostgresFullText {
 	return &PostgresFullText{repo: repo}
 }
 
-func (f *PostgresFullText) SearchComments(ctx context.Context, query string, limit, offset int) ([]*domain.Comment, error) {
-	return f.repo.Search(ctx, query, limit, offset)
+func (f *PostgresFullText) SearchComments(ctx context.Context, query string, page domain.Page) ([]*domain.SearchHit, error) {
+	return f.repo.Search(ctx, query, page)
 }
@@ -0,0 +1,55 @@
+// Package logging связывает логгер с контекстом запроса: middleware кладёт
+// в контекст дочерний логгер zlog с request_id, usecase дополняет его
+// comment_id и parent_id, а все нижележащие слои пишут через From(ctx).
+package logging
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/wb-go/wbf/zlog"
+)
+
+type loggerKey struct{}
+
+// SetLevel задаёт глобальный уровень логирования: debug, info, warn, error.
+// Пустая строка оставляет уровень по умолчанию.
+func SetLevel(level string) error {
+	if level == "" {
+		return nil
+	}
+	lvl, err := zerolog.ParseLevel(strings.ToLower(strings.TrimSpace(level)))
+	if err != nil {
+		return err
+	}
+	zerolog.SetGlobalLevel(lvl)
+	return nil
+}
+
+// WithLogger кладёт логгер в контекст.
+func WithLogger(ctx context.Context, l zerolog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, &l)
+}
+
+// From возвращает логгер из контекста, а если его нет — глобальный zlog.Logger.
+func From(ctx context.Context) *zerolog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*zerolog.Logger); ok {
+		return l
+	}
+	return &zlog.Logger
+}
+
+// WithCommentID добавляет к логгеру контекста поле comment_id.
+func WithCommentID(ctx context.Context, id int64) context.Context {
+	return WithLogger(ctx, From(ctx).With().Int64("comment_id", id).Logger())
+}
+
+// WithParentID добавляет к логгеру контекста поле parent_id; nil — ответ на
+// верхний уровень, поле не добавляется.
+func WithParentID(ctx context.Context, parentID *int64) context.Context {
+	if parentID == nil {
+		return ctx
+	}
+	return WithLogger(ctx, From(ctx).With().Int64("parent_id", *parentID).Logger())
+}
@@ -0,0 +1,140 @@
+// Package metrics — метрики Prometheus сервиса: HTTP, запросы репозитория,
+// повторы и пул подключений к базе.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/yokitheyo/wb_level3_3/internal/infrastructure/cache"
+)
+
+const namespace = "commenttree"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// Потоки SSE живут минутами и часами; в общей гистограмме они сдвигали
+	// бы квантили задержки всех маршрутов.
+	streamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_stream_duration_seconds",
+		Help:      "Server-Sent Events stream lifetime by route.",
+		Buckets:   []float64{1, 10, 60, 300, 900, 1800, 3600, 7200},
+	}, []string{"route"})
+
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repository_query_duration_seconds",
+		Help:      "Comment repository call latency by method.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"method"})
+
+	queryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "repository_errors_total",
+		Help:      "Comment repository calls that failed with an unexpected error, by method.",
+	}, []string{"method"})
+
+	retryAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retry_attempts_total",
+		Help:      "Attempts made under a retry strategy, by operation and result.",
+	}, []string{"operation", "result"})
+
+	retryExhausted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retry_exhausted_total",
+		Help:      "Operations that failed after all retry attempts, by operation.",
+	}, []string{"operation"})
+)
+
+// Handler отдаёт метрики в формате Prometheus.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveHTTP учитывает завершённый HTTP-запрос. route — шаблон маршрута
+// (например, /comments/:id), чтобы число серий не зависело от id.
+func ObserveHTTP(method, route string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(d.Seconds())
+}
+
+// ObserveStream учитывает закрытый поток SSE: запрос считается в
+// http_requests_total, а время жизни — отдельно от задержек запросов.
+func ObserveStream(method, route string, status int, d time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	streamDuration.WithLabelValues(route).Observe(d.Seconds())
+}
+
+// ObserveQuery учитывает вызов метода репозитория; failed — неожиданная
+// ошибка (не ошибка предметной области).
+func ObserveQuery(method string, d time.Duration, failed bool) {
+	queryDuration.WithLabelValues(method).Observe(d.Seconds())
+	if failed {
+		queryErrors.WithLabelValues(method).Inc()
+	}
+}
+
+// RetryAttempt учитывает одну попытку операции под стратегией повторов.
+func RetryAttempt(operation string, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	retryAttempts.WithLabelValues(operation, result).Inc()
+}
+
+// RetryExhausted учитывает операцию, исчерпавшую все попытки.
+func RetryExhausted(operation string) {
+	retryExhausted.WithLabelValues(operation).Inc()
+}
+
+// RegisterDBStats публикует статистику пула подключений db под именем name
+// (master, replica_0, ...).
+func RegisterDBStats(db *sql.DB, name string) error {
+	return prometheus.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterCacheStats публикует счётчики in-memory кэша.
+func RegisterCacheStats(stats func() cache.Stats) error {
+	cs := []prometheus.Collector{
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace, Name: "cache_hits_total", Help: "In-memory cache hits.",
+		}, func() float64 { return float64(stats().Hits) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace, Name: "cache_misses_total", Help: "In-memory cache misses.",
+		}, func() float64 { return float64(stats().Misses) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace, Name: "cache_evictions_total", Help: "In-memory cache evictions.",
+		}, func() float64 { return float64(stats().Evictions) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "cache_entries", Help: "In-memory cache entries.",
+		}, func() float64 { return float64(stats().Entries) }),
+	}
+	for _, c := range cs {
+		if err := prometheus.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
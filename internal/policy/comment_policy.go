@@ -0,0 +1,96 @@
+// Package policy содержит правила доступа к комментариям. Правила не зависят
+// от HTTP и принимают только пользователя и комментарий.
+package policy
+
+import (
+	"fmt"
+
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+)
+
+// CommentPolicy решает, что пользователь может делать с комментарием:
+// автор распоряжается своим комментарием, модератор — любым.
+type CommentPolicy struct {
+	moderatorRoles []string
+}
+
+// NewCommentPolicy создаёт политику; пользователи с любой из moderatorRoles
+// считаются модераторами.
+func NewCommentPolicy(moderatorRoles []string) *CommentPolicy {
+	return &CommentPolicy{moderatorRoles: moderatorRoles}
+}
+
+// IsModerator сообщает, есть ли у пользователя одна из ролей модератора.
+func (p *CommentPolicy) IsModerator(principal *domain.Principal) bool {
+	if principal == nil {
+		return false
+	}
+	for _, role := range p.moderatorRoles {
+		if principal.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanEdit разрешает правку автору и модератору.
+func (p *CommentPolicy) CanEdit(principal *domain.Principal, c *domain.Comment) error {
+	if p.isOwner(principal, c) || p.IsModerator(principal) {
+		return nil
+	}
+	return fmt.Errorf("edit comment %d: %w", c.ID, domain.ErrForbidden)
+}
+
+// CanDelete разрешает автору удалить только сам комментарий; удаление
+// поддерева и окончательное удаление затрагивают чужие ответы и доступны
+// только модератору.
+func (p *CommentPolicy) CanDelete(principal *domain.Principal, c *domain.Comment, mode domain.DeleteMode) error {
+	if p.IsModerator(principal) {
+		return nil
+	}
+	if mode == domain.DeleteSingle && p.isOwner(principal, c) {
+		return nil
+	}
+	return fmt.Errorf("delete comment %d (mode %s): %w", c.ID, mode, domain.ErrForbidden)
+}
+
+// CanViewRevisions открывает историю правок автору и модератору. История
+// удалённого комментария хранит его скрытый текст, поэтому для всех, кроме
+// модератора, такой комментарий не существует.
+func (p *CommentPolicy) CanViewRevisions(principal *domain.Principal, c *domain.Comment) error {
+	if p.IsModerator(principal) {
+		return nil
+	}
+	if c.Deleted {
+		return fmt.Errorf("comment %d: %w", c.ID, domain.ErrNotFound)
+	}
+	if p.isOwner(principal, c) {
+		return nil
+	}
+	return fmt.Errorf("view revisions of comment %d: %w", c.ID, domain.ErrForbidden)
+}
+
+// CanRestore разрешает восстановление только модератору: иначе автор мог бы
+// вернуть комментарий, удалённый модерацией.
+func (p *CommentPolicy) CanRestore(principal *domain.Principal, id int64) error {
+	if p.IsModerator(principal) {
+		return nil
+	}
+	return fmt.Errorf("restore comment %d: %w", id, domain.ErrForbidden)
+}
+
+// CanLock разрешает закрывать и открывать комментарии для ответов только
+// модератору.
+func (p *CommentPolicy) CanLock(principal *domain.Principal, id int64) error {
+	if p.IsModerator(principal) {
+		return nil
+	}
+	return fmt.Errorf("lock comment %d: %w", id, domain.ErrForbidden)
+}
+
+// isOwner сверяет пользователя с владельцем комментария. Author — свободный
+// текст и для проверки прав не годится; комментарии без владельца не
+// принадлежат никому.
+func (p *CommentPolicy) isOwner(principal *domain.Principal, c *domain.Comment) bool {
+	return principal != nil && c.Owner != "" && principal.Subject == c.Owner
+}
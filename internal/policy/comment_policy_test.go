@@ -0,0 +1,152 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+)
+
+var (
+	owner     = &domain.Principal{Subject: "alice"}
+	moderator = &domain.Principal{Subject: "mod", Roles: []string{"moderator"}}
+	stranger  = &domain.Principal{Subject: "bob", Roles: []string{"user"}}
+)
+
+func newPolicy() *CommentPolicy {
+	return NewCommentPolicy([]string{"admin", "moderator"})
+}
+
+func checkAllowed(t *testing.T, err error, allowed bool) {
+	t.Helper()
+	switch {
+	case allowed && err != nil:
+		t.Errorf("got %v, want allowed", err)
+	case !allowed && !errors.Is(err, domain.ErrForbidden):
+		t.Errorf("got %v, want ErrForbidden", err)
+	}
+}
+
+func TestIsModerator(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *domain.Principal
+		want      bool
+	}{
+		{"owner", owner, false},
+		{"moderator", moderator, true},
+		{"second moderator role", &domain.Principal{Subject: "root", Roles: []string{"user", "admin"}}, true},
+		{"stranger", stranger, false},
+		{"nil principal", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newPolicy().IsModerator(tt.principal); got != tt.want {
+				t.Errorf("IsModerator = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanEdit(t *testing.T) {
+	owned := &domain.Comment{ID: 1, Author: "alice", Owner: "alice"}
+	legacy := &domain.Comment{ID: 2, Author: "alice"}
+	spoofed := &domain.Comment{ID: 3, Author: "bob", Owner: "alice"}
+
+	tests := []struct {
+		name      string
+		principal *domain.Principal
+		comment   *domain.Comment
+		allowed   bool
+	}{
+		{"owner", owner, owned, true},
+		{"moderator", moderator, owned, true},
+		{"stranger", stranger, owned, false},
+		{"nil principal", nil, owned, false},
+		{"author name without owner", owner, legacy, false},
+		{"moderator on comment without owner", moderator, legacy, true},
+		{"author name matches stranger", stranger, spoofed, false},
+		{"empty subject on comment without owner", &domain.Principal{}, legacy, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkAllowed(t, newPolicy().CanEdit(tt.principal, tt.comment), tt.allowed)
+		})
+	}
+}
+
+func TestCanDelete(t *testing.T) {
+	owned := &domain.Comment{ID: 1, Author: "alice", Owner: "alice"}
+	legacy := &domain.Comment{ID: 2, Author: "alice"}
+
+	tests := []struct {
+		name      string
+		principal *domain.Principal
+		comment   *domain.Comment
+		mode      domain.DeleteMode
+		allowed   bool
+	}{
+		{"owner single", owner, owned, domain.DeleteSingle, true},
+		{"owner subtree", owner, owned, domain.DeleteSubtree, false},
+		{"owner purge", owner, owned, domain.DeletePurge, false},
+		{"moderator single", moderator, owned, domain.DeleteSingle, true},
+		{"moderator subtree", moderator, owned, domain.DeleteSubtree, true},
+		{"moderator purge", moderator, owned, domain.DeletePurge, true},
+		{"stranger single", stranger, owned, domain.DeleteSingle, false},
+		{"nil principal single", nil, owned, domain.DeleteSingle, false},
+		{"author name without owner", owner, legacy, domain.DeleteSingle, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkAllowed(t, newPolicy().CanDelete(tt.principal, tt.comment, tt.mode), tt.allowed)
+		})
+	}
+}
+
+func TestCanViewRevisions(t *testing.T) {
+	owned := &domain.Comment{ID: 1, Author: "alice", Owner: "alice"}
+	deleted := &domain.Comment{ID: 2, Owner: "alice", Deleted: true}
+	legacy := &domain.Comment{ID: 3, Author: "alice"}
+
+	tests := []struct {
+		name      string
+		principal *domain.Principal
+		comment   *domain.Comment
+		want      error
+	}{
+		{"owner", owner, owned, nil},
+		{"moderator", moderator, owned, nil},
+		{"stranger", stranger, owned, domain.ErrForbidden},
+		{"nil principal", nil, owned, domain.ErrForbidden},
+		{"author name without owner", owner, legacy, domain.ErrForbidden},
+		{"owner of deleted", owner, deleted, domain.ErrNotFound},
+		{"stranger on deleted", stranger, deleted, domain.ErrNotFound},
+		{"moderator on deleted", moderator, deleted, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newPolicy().CanViewRevisions(tt.principal, tt.comment)
+			if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanRestore(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *domain.Principal
+		allowed   bool
+	}{
+		{"owner", owner, false},
+		{"moderator", moderator, true},
+		{"stranger", stranger, false},
+		{"nil principal", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkAllowed(t, newPolicy().CanRestore(tt.principal, 1), tt.allowed)
+		})
+	}
+}
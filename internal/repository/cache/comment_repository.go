@@ -0,0 +1,298 @@
+// Package cache — кэширующий декоратор domain.CommentRepository.
+package cache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+	infracache "github.com/yokitheyo/wb_level3_3/internal/infrastructure/cache"
+	"github.com/yokitheyo/wb_level3_3/internal/infrastructure/database"
+	"github.com/yokitheyo/wb_level3_3/internal/logging"
+)
+
+// Счётчики версий. Каждый закэшированный ключ содержит версии, от которых
+// зависит значение:
+//   - FindByID — versionAll и версию комментария;
+//   - FindChildren — versionAll и версию списка ответов родителя;
+//   - FindDescendants — versionAll и версии поддеревьев каждого корня.
+//
+// Save, Update и SetLocked затрагивают один комментарий, список его родителя
+// и поддеревья его предков, поэтому увеличивают только эти версии; деревья
+// других тредов остаются в кэше. Delete, Restore и PurgeDeleted меняют
+// видимость целых ветвей и надгробий у предков, поэтому сбрасывают всё через
+// versionAll.
+const versionAll = "ver:all"
+
+func versionComment(id int64) string {
+	return "ver:comment:" + strconv.FormatInt(id, 10)
+}
+
+func versionSubtree(id int64) string {
+	return "ver:subtree:" + strconv.FormatInt(id, 10)
+}
+
+func versionChildren(parentID *int64) string {
+	if parentID == nil {
+		return "ver:children:root"
+	}
+	return "ver:children:" + strconv.FormatInt(*parentID, 10)
+}
+
+// CommentRepository кэширует FindByID, FindChildren и FindDescendants.
+// Остальные методы передаются обёрнутому репозиторию без изменений. Ошибки
+// кэша не считаются ошибками запроса: чтение идёт в базу напрямую.
+type CommentRepository struct {
+	domain.CommentRepository
+	cache infracache.Cache
+	ttl   time.Duration
+	// failed считает неудачные увеличения версий, reset — сколько из них
+	// покрыто последним увеличением versionAll. Пока они различаются, в кэше
+	// могут быть значения, устаревшие после записи, и он не используется.
+	failed atomic.Int64
+	reset  atomic.Int64
+}
+
+func NewCommentRepository(repo domain.CommentRepository, cache infracache.Cache, ttl time.Duration) *CommentRepository {
+	return &CommentRepository{CommentRepository: repo, cache: cache, ttl: ttl}
+}
+
+type pendingKey struct{}
+
+// pending копит версии, которые нужно увеличить после фиксации транзакции:
+// увеличенные раньше, они позволили бы параллельному чтению закэшировать
+// ещё не зафиксированное состояние под новой версией.
+type pending struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (r *CommentRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(pendingKey{}).(*pending); ok {
+		return r.CommentRepository.WithinTx(ctx, fn)
+	}
+
+	p := &pending{}
+	if err := r.CommentRepository.WithinTx(context.WithValue(ctx, pendingKey{}, p), fn); err != nil {
+		return err
+	}
+	r.bump(ctx, p.keys...)
+	return nil
+}
+
+func (r *CommentRepository) Save(ctx context.Context, comment *domain.Comment) error {
+	if err := r.CommentRepository.Save(ctx, comment); err != nil {
+		return err
+	}
+	r.invalidate(ctx, append(r.ancestorVersions(ctx, comment.ParentID), versionChildren(comment.ParentID))...)
+	return nil
+}
+
+func (r *CommentRepository) Update(ctx context.Context, comment *domain.Comment) error {
+	if err := r.CommentRepository.Update(ctx, comment); err != nil {
+		return err
+	}
+	r.invalidate(ctx, append(r.ancestorVersions(ctx, comment.ParentID), versionComment(comment.ID), versionChildren(comment.ParentID))...)
+	return nil
+}
+
+func (r *CommentRepository) Delete(ctx context.Context, id int64, mode domain.DeleteMode) (int64, error) {
+	affected, err := r.CommentRepository.Delete(ctx, id, mode)
+	if err != nil {
+		return 0, err
+	}
+	r.invalidate(ctx, versionAll)
+	return affected, nil
+}
+
+func (r *CommentRepository) Restore(ctx context.Context, id int64, subtree bool, restoredBy string) (int64, error) {
+	affected, err := r.CommentRepository.Restore(ctx, id, subtree, restoredBy)
+	if err != nil {
+		return 0, err
+	}
+	r.invalidate(ctx, versionAll)
+	return affected, nil
+}
+
+func (r *CommentRepository) SetLocked(ctx context.Context, id int64, locked bool) (*domain.Comment, error) {
+	c, err := r.CommentRepository.SetLocked(ctx, id, locked)
+	if err != nil {
+		return nil, err
+	}
+	r.invalidate(ctx, append(r.ancestorVersions(ctx, c.ParentID), versionComment(c.ID), versionChildren(c.ParentID))...)
+	return c, nil
+}
+
+func (r *CommentRepository) PurgeDeleted(ctx context.Context, olderThan time.Time, limit int) (int64, error) {
+	purged, err := r.CommentRepository.PurgeDeleted(ctx, olderThan, limit)
+	if err != nil {
+		return 0, err
+	}
+	if purged > 0 {
+		r.invalidate(ctx, versionAll)
+	}
+	return purged, nil
+}
+
+func (r *CommentRepository) FindByID(ctx context.Context, id int64) (*domain.Comment, error) {
+	key := "comment:" + strconv.FormatInt(id, 10)
+	return load(ctx, r, key, []string{versionAll, versionComment(id)}, func(ctx context.Context) (*domain.Comment, error) {
+		return r.CommentRepository.FindByID(ctx, id)
+	})
+}
+
+func (r *CommentRepository) FindChildren(ctx context.Context, parentID *int64, page domain.Page) ([]*domain.Comment, error) {
+	parent := "root"
+	if parentID != nil {
+		parent = strconv.FormatInt(*parentID, 10)
+	}
+	key := fmt.Sprintf("children:%s:%s", parent, pageKey(page))
+	return load(ctx, r, key, []string{versionAll, versionChildren(parentID)}, func(ctx context.Context) ([]*domain.Comment, error) {
+		return r.CommentRepository.FindChildren(ctx, parentID, page)
+	})
+}
+
+func (r *CommentRepository) FindDescendants(ctx context.Context, rootIDs []int64, maxDepth int) ([]*domain.Comment, error) {
+	ids := make([]string, len(rootIDs))
+	for i, id := range rootIDs {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	sum := sha1.Sum([]byte(strings.Join(ids, ",")))
+	key := fmt.Sprintf("tree:%d:%s", maxDepth, hex.EncodeToString(sum[:]))
+	versions := []string{versionAll}
+	for _, id := range rootIDs {
+		versions = append(versions, versionSubtree(id))
+	}
+	return load(ctx, r, key, versions, func(ctx context.Context) ([]*domain.Comment, error) {
+		return r.CommentRepository.FindDescendants(ctx, rootIDs, maxDepth)
+	})
+}
+
+// ancestorVersions возвращает версии поддеревьев, в которые входит
+// комментарий с родителем parentID: самого родителя и всех его предков.
+// Если предков прочитать не удалось, сбрасывается весь кэш.
+func (r *CommentRepository) ancestorVersions(ctx context.Context, parentID *int64) []string {
+	if parentID == nil {
+		return nil
+	}
+	ids, err := r.CommentRepository.FindAncestorIDs(ctx, *parentID)
+	if err != nil {
+		logging.From(ctx).Error().Err(err).Int64("parent_id", *parentID).Msg("cache: ancestors lookup failed, dropping all entries")
+		return []string{versionAll}
+	}
+	versions := make([]string, len(ids))
+	for i, id := range ids {
+		versions[i] = versionSubtree(id)
+	}
+	return versions
+}
+
+// load отдаёт значение из кэша или получает его через fetch и кэширует.
+// Внутри транзакции и при запрошенном чтении с master кэш не используется:
+// там нужны актуальные данные. Промах читается с master: отстающая реплика
+// отдала бы состояние до записи, и оно легло бы в кэш под новой версией на
+// весь TTL. Без кэша чтения идут как обычно, в том числе на реплики.
+func load[T any](ctx context.Context, r *CommentRepository, key string, versionKeys []string, fetch func(ctx context.Context) (T, error)) (T, error) {
+	if _, inTx := ctx.Value(pendingKey{}).(*pending); inTx || database.PrimaryRequested(ctx) || !r.clean(ctx) {
+		return fetch(ctx)
+	}
+
+	versions, err := r.cache.Versions(ctx, versionKeys...)
+	if err != nil {
+		logCacheError(ctx, err, "cache: read versions failed")
+		return fetch(ctx)
+	}
+	parts := make([]string, len(versions))
+	for i, v := range versions {
+		parts[i] = strconv.FormatInt(v, 10)
+	}
+	key += "@" + strings.Join(parts, ".")
+
+	data, ok, err := r.cache.Get(ctx, key)
+	if err != nil {
+		logCacheError(ctx, err, "cache: get failed")
+	} else if ok {
+		var v T
+		if err := json.Unmarshal(data, &v); err == nil {
+			return v, nil
+		}
+		logging.From(ctx).Warn().Err(err).Str("key", key).Msg("cache: corrupt entry")
+	}
+
+	v, err := fetch(database.WithPrimary(ctx))
+	if err != nil {
+		return v, err
+	}
+	if data, err := json.Marshal(v); err == nil {
+		if err := r.cache.Set(ctx, key, data, r.ttl); err != nil {
+			logCacheError(ctx, err, "cache: set failed")
+		}
+	}
+	return v, nil
+}
+
+// invalidate увеличивает версии сразу или, внутри транзакции, после её
+// фиксации.
+func (r *CommentRepository) invalidate(ctx context.Context, keys ...string) {
+	if p, ok := ctx.Value(pendingKey{}).(*pending); ok {
+		p.mu.Lock()
+		p.keys = append(p.keys, keys...)
+		p.mu.Unlock()
+		return
+	}
+	r.bump(ctx, keys...)
+}
+
+// bump увеличивает версии. Если кэш недоступен, сбой запоминается: после
+// восстановления кэша clean сбросит его целиком.
+func (r *CommentRepository) bump(ctx context.Context, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	if err := r.cache.Bump(context.WithoutCancel(ctx), keys...); err != nil {
+		r.failed.Add(1)
+		logging.From(ctx).Error().Err(err).Strs("keys", keys).Msg("cache: invalidation failed, cache bypassed until reset")
+	}
+}
+
+// clean сообщает, можно ли читать из кэша. После неудачного увеличения
+// версий пробует увеличить versionAll; пока не получится, кэш обходится.
+func (r *CommentRepository) clean(ctx context.Context) bool {
+	failed := r.failed.Load()
+	if failed == r.reset.Load() {
+		return true
+	}
+	if err := r.cache.Bump(context.WithoutCancel(ctx), versionAll); err != nil {
+		return false
+	}
+	r.reset.Store(failed)
+	logging.From(ctx).Info().Msg("cache: reset after failed invalidation")
+	return true
+}
+
+func logCacheError(ctx context.Context, err error, msg string) {
+	if errors.Is(err, infracache.ErrUnavailable) {
+		return
+	}
+	logging.From(ctx).Warn().Err(err).Msg(msg)
+}
+
+func pageKey(p domain.Page) string {
+	if p.After != nil {
+		rank := ""
+		if p.After.Rank != nil {
+			rank = strconv.FormatFloat(*p.After.Rank, 'g', -1, 64)
+		}
+		return fmt.Sprintf("%d:%s:after:%d:%d:%s", p.Limit, p.Sort, p.After.CreatedAt.UnixMicro(), p.After.ID, rank)
+	}
+	return fmt.Sprintf("%d:%s:offset:%d", p.Limit, p.Sort, p.Offset)
+}
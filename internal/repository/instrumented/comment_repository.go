@@ -0,0 +1,139 @@
+// Package instrumented — декоратор domain.CommentRepository, снимающий
+// метрики и открывающий спан трассировки на каждый вызов.
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+	"github.com/yokitheyo/wb_level3_3/internal/metrics"
+	"github.com/yokitheyo/wb_level3_3/internal/tracing"
+)
+
+// CommentRepository измеряет длительность вызовов обёрнутого репозитория,
+// считает неожиданные ошибки и пишет спаны с числом затронутых строк.
+// Ошибки предметной области (не найдено, конфликт) сбоями не считаются.
+type CommentRepository struct {
+	next domain.CommentRepository
+}
+
+func NewCommentRepository(next domain.CommentRepository) *CommentRepository {
+	return &CommentRepository{next: next}
+}
+
+// start открывает спан метода. Возвращённую функцию нужно вызвать через
+// defer с итоговой ошибкой и числом строк (отрицательное — не записывать).
+func start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, func(err error, rows int64)) {
+	begin := time.Now()
+	ctx, span := tracing.Start(ctx, "CommentRepository."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs, attribute.String("db.system", "postgresql"))...),
+	)
+	return ctx, func(err error, rows int64) {
+		metrics.ObserveQuery(method, time.Since(begin), err != nil && !domain.IsExpected(err))
+		if err == nil && rows >= 0 {
+			span.SetAttributes(attribute.Int64("db.rows", rows))
+		}
+		tracing.End(span, err)
+	}
+}
+
+// WithinTx не измеряется: его длительность складывается из вложенных вызовов.
+func (r *CommentRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.next.WithinTx(ctx, fn)
+}
+
+func (r *CommentRepository) Save(ctx context.Context, comment *domain.Comment) (err error) {
+	ctx, done := start(ctx, "Save")
+	defer func() { done(err, 1) }()
+	return r.next.Save(ctx, comment)
+}
+
+func (r *CommentRepository) FindByID(ctx context.Context, id int64) (_ *domain.Comment, err error) {
+	ctx, done := start(ctx, "FindByID", attribute.Int64("comment.id", id))
+	defer func() { done(err, 1) }()
+	return r.next.FindByID(ctx, id)
+}
+
+func (r *CommentRepository) LockForReply(ctx context.Context, id int64) (_ *domain.Comment, err error) {
+	ctx, done := start(ctx, "LockForReply", attribute.Int64("comment.id", id))
+	defer func() { done(err, 1) }()
+	return r.next.LockForReply(ctx, id)
+}
+
+func (r *CommentRepository) FindRootID(ctx context.Context, id int64) (_ int64, err error) {
+	ctx, done := start(ctx, "FindRootID", attribute.Int64("comment.id", id))
+	defer func() { done(err, 1) }()
+	return r.next.FindRootID(ctx, id)
+}
+
+func (r *CommentRepository) FindAncestorIDs(ctx context.Context, id int64) (out []int64, err error) {
+	ctx, done := start(ctx, "FindAncestorIDs", attribute.Int64("comment.id", id))
+	defer func() { done(err, int64(len(out))) }()
+	return r.next.FindAncestorIDs(ctx, id)
+}
+
+func (r *CommentRepository) FindChildren(ctx context.Context, parentID *int64, page domain.Page) (out []*domain.Comment, err error) {
+	var attrs []attribute.KeyValue
+	if parentID != nil {
+		attrs = append(attrs, attribute.Int64("comment.parent_id", *parentID))
+	}
+	ctx, done := start(ctx, "FindChildren", attrs...)
+	defer func() { done(err, int64(len(out))) }()
+	return r.next.FindChildren(ctx, parentID, page)
+}
+
+func (r *CommentRepository) FindDescendants(ctx context.Context, rootIDs []int64, maxDepth int) (out []*domain.Comment, err error) {
+	ctx, done := start(ctx, "FindDescendants",
+		attribute.Int("comment.roots", len(rootIDs)),
+		attribute.Int("comment.max_depth", maxDepth),
+	)
+	defer func() { done(err, int64(len(out))) }()
+	return r.next.FindDescendants(ctx, rootIDs, maxDepth)
+}
+
+func (r *CommentRepository) Update(ctx context.Context, comment *domain.Comment) (err error) {
+	ctx, done := start(ctx, "Update", attribute.Int64("comment.id", comment.ID))
+	defer func() { done(err, 1) }()
+	return r.next.Update(ctx, comment)
+}
+
+func (r *CommentRepository) FindRevisions(ctx context.Context, commentID int64) (out []*domain.Revision, err error) {
+	ctx, done := start(ctx, "FindRevisions", attribute.Int64("comment.id", commentID))
+	defer func() { done(err, int64(len(out))) }()
+	return r.next.FindRevisions(ctx, commentID)
+}
+
+func (r *CommentRepository) Delete(ctx context.Context, id int64, mode domain.DeleteMode) (affected int64, err error) {
+	ctx, done := start(ctx, "Delete", attribute.Int64("comment.id", id), attribute.String("comment.delete_mode", string(mode)))
+	defer func() { done(err, affected) }()
+	return r.next.Delete(ctx, id, mode)
+}
+
+func (r *CommentRepository) Restore(ctx context.Context, id int64, subtree bool, restoredBy string) (affected int64, err error) {
+	ctx, done := start(ctx, "Restore", attribute.Int64("comment.id", id), attribute.Bool("comment.subtree", subtree))
+	defer func() { done(err, affected) }()
+	return r.next.Restore(ctx, id, subtree, restoredBy)
+}
+
+func (r *CommentRepository) SetLocked(ctx context.Context, id int64, locked bool) (_ *domain.Comment, err error) {
+	ctx, done := start(ctx, "SetLocked", attribute.Int64("comment.id", id), attribute.Bool("comment.locked", locked))
+	defer func() { done(err, 1) }()
+	return r.next.SetLocked(ctx, id, locked)
+}
+
+func (r *CommentRepository) PurgeDeleted(ctx context.Context, olderThan time.Time, limit int) (purged int64, err error) {
+	ctx, done := start(ctx, "PurgeDeleted", attribute.Int("db.batch_size", limit))
+	defer func() { done(err, purged) }()
+	return r.next.PurgeDeleted(ctx, olderThan, limit)
+}
+
+func (r *CommentRepository) Search(ctx context.Context, query string, page domain.Page) (out []*domain.SearchHit, err error) {
+	ctx, done := start(ctx, "Search")
+	defer func() { done(err, int64(len(out))) }()
+	return r.next.Search(ctx, query, page)
+}
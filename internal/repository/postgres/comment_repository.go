@@ -3,252 +3,663 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/wb-go/wbf/retry"
-	"github.com/wb-go/wbf/zlog"
 
 	"github.com/wb-go/wbf/dbpg"
 	"github.com/yokitheyo/wb_level3_3/internal/domain"
+	"github.com/yokitheyo/wb_level3_3/internal/infrastructure/database"
+	"github.com/yokitheyo/wb_level3_3/internal/logging"
 )
 
+// searchConfig — конфигурация текстового поиска; должна совпадать с той,
+// что использует триггер заполнения content_tsv.
+const searchConfig = "simple"
+
+// commentRepository пишет в db.Master, а читает вне транзакций через
+// replicas: с реплики, если контекст не требует чтения с master.
 type commentRepository struct {
 	db       *dbpg.DB
+	replicas *database.ReplicaSet
 	strategy retry.Strategy
 }
 
-func NewCommentRepository(db *dbpg.DB, strategy retry.Strategy) domain.CommentRepository {
-	return &commentRepository{db: db, strategy: strategy}
+func NewCommentRepository(db *dbpg.DB, replicas *database.ReplicaSet, strategy retry.Strategy) domain.CommentRepository {
+	return &commentRepository{db: db, replicas: replicas, strategy: strategy}
 }
 
 func (r *commentRepository) Save(ctx context.Context, c *domain.Comment) error {
 	query := `
-    INSERT INTO comments (parent_id, author, content, deleted)
-    VALUES ($1, $2, $3, $4)
+    INSERT INTO comments (parent_id, author, content, deleted, owner)
+    VALUES ($1, $2, $3, $4, NULLIF($5, ''))
     RETURNING id, created_at, updated_at
 `
-	return r.db.Master.QueryRowContext(ctx, query,
+	err := r.master(ctx).QueryRowContext(ctx, query,
 		c.ParentID,
 		c.Author,
 		c.Content,
 		c.Deleted,
+		c.Owner,
 	).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+	if isForeignKeyViolation(err) && c.ParentID != nil {
+		return fmt.Errorf("parent comment %d: %w", *c.ParentID, domain.ErrNotFound)
+	}
+	return err
 }
 
 func (r *commentRepository) FindByID(ctx context.Context, id int64) (*domain.Comment, error) {
 	query := `
-		SELECT id, parent_id, author, content, created_at, updated_at, deleted
+		SELECT ` + commentColumns + `
 		FROM comments
 		WHERE id = $1
 	`
 
-	c := &domain.Comment{}
-	var parent sql.NullInt64
-	var updated sql.NullTime
-
-	row := r.db.Master.QueryRowContext(ctx, query, id)
-	err := row.Scan(&c.ID, &parent, &c.Author, &c.Content, &c.CreatedAt, &updated, &c.Deleted)
+	c, err := scanComment(r.reader(ctx).QueryRowContext(ctx, query, id))
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("comment %d: %w", id, domain.ErrNotFound)
 		}
-		zlog.Logger.Error().Err(err).Msg("FindByID failed")
+		logging.From(ctx).Error().Err(err).Msg("FindByID failed")
 		return nil, err
 	}
 
-	if parent.Valid {
-		c.ParentID = &parent.Int64
+	return c, nil
+}
+
+// LockForReply блокирует комментарий и всех его предков от изменения до
+// конца транзакции (см. lockAncestors) и возвращает его с заполненным
+// Depth — числом предков. Вызывается внутри WithinTx перед сохранением
+// ответа.
+func (r *commentRepository) LockForReply(ctx context.Context, id int64) (*domain.Comment, error) {
+	locked, err := r.lockAncestors(ctx, id)
+	if err != nil {
+		logging.From(ctx).Error().Err(err).Msg("LockForReply failed")
+		return nil, err
 	}
-	if updated.Valid {
-		c.UpdatedAt = &updated.Time
+	if locked == 0 {
+		return nil, fmt.Errorf("parent comment %d: %w", id, domain.ErrNotFound)
 	}
 
+	c, err := scanComment(r.master(ctx).QueryRowContext(ctx, `
+		SELECT `+commentColumns+`
+		FROM comments
+		WHERE id = $1
+	`, id))
+	if err != nil {
+		logging.From(ctx).Error().Err(err).Msg("LockForReply failed")
+		return nil, err
+	}
+	c.Depth = locked - 1
 	return c, nil
 }
 
-func (r *commentRepository) FindChildren(ctx context.Context, parentID *int64, limit, offset int, sort string) ([]*domain.Comment, error) {
-	order := "created_at ASC"
-	if sort == "desc" {
-		order = "created_at DESC"
+// lockAncestors блокирует FOR NO KEY UPDATE комментарий id и всех его
+// предков, начиная с корня треда, и возвращает число заблокированных строк
+// (0 — комментария нет). Триггер live_descendants (миграция 00008) при
+// вставке, удалении и восстановлении меняет счётчики предков снизу вверх;
+// если бы каждая транзакция брала блокировки в своём порядке — вставка
+// снизу вверх, удаление поддерева сверху вниз, — их встречные ожидания
+// заканчивались бы взаимоблокировкой. Поэтому все такие изменения сначала
+// берут цепочку сверху вниз этим методом, и триггер обновляет уже
+// заблокированные строки.
+func (r *commentRepository) lockAncestors(ctx context.Context, id int64) (int, error) {
+	rows, err := r.master(ctx).QueryContext(ctx, `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, 0 AS height FROM comments WHERE id = $1
+			UNION ALL
+			SELECT c.id, c.parent_id, a.height + 1
+			FROM comments c
+			JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT c.id
+		FROM comments c
+		JOIN ancestors a ON a.id = c.id
+		ORDER BY a.height DESC
+		FOR NO KEY UPDATE OF c
+	`, id)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	locked := 0
+	for rows.Next() {
+		locked++
+	}
+	return locked, rows.Err()
+}
+
+func (r *commentRepository) FindRootID(ctx context.Context, id int64) (int64, error) {
+	var rootID int64
+	err := r.master(ctx).QueryRowContext(ctx, `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM comments WHERE id = $1
+			UNION ALL
+			SELECT c.id, c.parent_id
+			FROM comments c
+			JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT id FROM ancestors WHERE parent_id IS NULL
+	`, id).Scan(&rootID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("comment %d: %w", id, domain.ErrNotFound)
+		}
+		logging.From(ctx).Error().Err(err).Msg("FindRootID failed")
+		return 0, err
 	}
+	return rootID, nil
+}
 
-	var query string
+func (r *commentRepository) FindAncestorIDs(ctx context.Context, id int64) ([]int64, error) {
+	rows, err := r.master(ctx).QueryContext(ctx, `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM comments WHERE id = $1
+			UNION ALL
+			SELECT c.id, c.parent_id
+			FROM comments c
+			JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT id FROM ancestors
+	`, id)
+	if err != nil {
+		logging.From(ctx).Error().Err(err).Msg("FindAncestorIDs failed")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var ancestor int64
+		if err := rows.Scan(&ancestor); err != nil {
+			return nil, err
+		}
+		ids = append(ids, ancestor)
+	}
+	return ids, rows.Err()
+}
+
+func (r *commentRepository) FindChildren(ctx context.Context, parentID *int64, page domain.Page) ([]*domain.Comment, error) {
+	order, cmp := "ASC", ">"
+	if page.Sort == "desc" {
+		order, cmp = "DESC", "<"
+	}
+
+	var conds []string
 	var args []interface{}
 
 	if parentID == nil {
-		query = fmt.Sprintf(`
-			SELECT id, parent_id, author, content, created_at, updated_at, deleted
-			FROM comments
-			WHERE parent_id IS NULL AND deleted = false
-			ORDER BY %s
-			LIMIT $1 OFFSET $2
-		`, order)
-		args = []interface{}{limit, offset}
+		conds = append(conds, "parent_id IS NULL")
 	} else {
-		query = fmt.Sprintf(`
-			SELECT id, parent_id, author, content, created_at, updated_at, deleted
-			FROM comments
-			WHERE parent_id = $1 AND deleted = false
-			ORDER BY %s
-			LIMIT $2 OFFSET $3
-		`, order)
-		args = []interface{}{*parentID, limit, offset}
+		args = append(args, *parentID)
+		conds = append(conds, fmt.Sprintf("parent_id = $%d", len(args)))
+	}
+	conds = append(conds, "(deleted = false OR live_descendants > 0)")
+
+	keyset, limit := pageClauses(page, cmp, &args)
+	if keyset != "" {
+		conds = append(conds, keyset)
 	}
 
-	rows, err := r.db.QueryWithRetry(ctx, r.strategy, query, args...)
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM comments
+		WHERE %s
+		ORDER BY created_at %s, id %s
+		%s
+	`, commentColumns, strings.Join(conds, " AND "), order, order, limit)
+
+	rows, err := r.query(ctx, query, args...)
 	if err != nil {
-		zlog.Logger.Error().Err(err).Msg("FindChildren query failed")
+		logging.From(ctx).Error().Err(err).Msg("FindChildren query failed")
 		return nil, err
 	}
 	defer rows.Close()
 
 	var comments []*domain.Comment
 	for rows.Next() {
-		c := &domain.Comment{}
-		var parent sql.NullInt64
-		var updated sql.NullTime
-
-		if err := rows.Scan(
-			&c.ID,
-			&parent,
-			&c.Author,
-			&c.Content,
-			&c.CreatedAt,
-			&updated,
-			&c.Deleted,
-		); err != nil {
-			zlog.Logger.Error().Err(err).Msg("FindChildren scan failed")
+		c, err := scanComment(rows)
+		if err != nil {
+			logging.From(ctx).Error().Err(err).Msg("FindChildren scan failed")
 			return nil, err
 		}
-
-		if parent.Valid {
-			c.ParentID = &parent.Int64
-		}
-		if updated.Valid {
-			c.UpdatedAt = &updated.Time
-		}
-
 		comments = append(comments, c)
 	}
 
 	if err := rows.Err(); err != nil {
-		zlog.Logger.Error().Err(err).Msg("FindChildren rows iteration failed")
+		logging.From(ctx).Error().Err(err).Msg("FindChildren rows iteration failed")
 		return nil, err
 	}
 
-	zlog.Logger.Info().Msgf("FindChildren returned %d comments for parent_id=%v", len(comments), parentID)
+	logging.From(ctx).Info().Int("count", len(comments)).Msg("FindChildren returned comments")
 	return comments, nil
 }
 
-func (r *commentRepository) Delete(ctx context.Context, id int64) error {
-	_, err := r.db.ExecWithRetry(ctx, r.strategy, `
-		UPDATE comments
-		SET deleted = true, updated_at = $2
-		WHERE id = $1
-	`, id, time.Now())
-	return err
-}
+func (r *commentRepository) FindDescendants(ctx context.Context, rootIDs []int64, maxDepth int) ([]*domain.Comment, error) {
+	if len(rootIDs) == 0 {
+		return nil, nil
+	}
 
-func (r *commentRepository) Search(ctx context.Context, q string, limit, offset int) ([]*domain.Comment, error) {
 	query := `
-		SELECT id, parent_id, author, content, created_at, updated_at, deleted
-		FROM comments
-		WHERE (content ILIKE '%' || $1 || '%' OR author ILIKE '%' || $1 || '%')
-		AND deleted = false
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
+		WITH RECURSIVE tree AS (
+			SELECT ` + commentColumns + `,
+			       1 AS depth, ARRAY[parent_id, id] AS path
+			FROM comments
+			WHERE parent_id = ANY($1)
+			UNION ALL
+			SELECT c.id, c.parent_id, c.author, c.content, c.created_at, c.updated_at, c.deleted, c.locked,
+			       c.restored_at, c.restored_by, c.live_descendants, c.owner, t.depth + 1, t.path || c.id
+			FROM comments c
+			JOIN tree t ON c.parent_id = t.id
+			WHERE $2 <= 0 OR t.depth < $2
+		)
+		SELECT ` + commentColumns + `, depth, path
+		FROM tree
+		WHERE deleted = false OR live_descendants > 0
+		ORDER BY depth, created_at, id
 	`
 
-	zlog.Logger.Info().Msgf("Simple search query: %s, limit: %d, offset: %d", q, limit, offset)
-
-	rows, err := r.db.QueryWithRetry(ctx, r.strategy, query, q, limit, offset)
+	rows, err := r.query(ctx, query, pq.Array(rootIDs), maxDepth)
 	if err != nil {
-		zlog.Logger.Error().Err(err).Msg("Search query failed")
+		logging.From(ctx).Error().Err(err).Msg("FindDescendants query failed")
 		return nil, err
 	}
 	defer rows.Close()
 
 	var comments []*domain.Comment
 	for rows.Next() {
-		c := &domain.Comment{}
-		var parent sql.NullInt64
-		var updated sql.NullTime
-
-		if err := rows.Scan(
-			&c.ID,
-			&parent,
-			&c.Author,
-			&c.Content,
-			&c.CreatedAt,
-			&updated,
-			&c.Deleted,
-		); err != nil {
-			zlog.Logger.Error().Err(err).Msg("Search scan failed")
-			return nil, err
-		}
+		var depth int
+		var path pq.Int64Array
 
-		if parent.Valid {
-			c.ParentID = &parent.Int64
-		}
-		if updated.Valid {
-			c.UpdatedAt = &updated.Time
+		c, err := scanComment(rows, &depth, &path)
+		if err != nil {
+			logging.From(ctx).Error().Err(err).Msg("FindDescendants scan failed")
+			return nil, err
 		}
+		c.Depth = depth
+		c.Path = path
 
 		comments = append(comments, c)
 	}
 
-	zlog.Logger.Info().Msgf("Simple search returned %d comments for query: %s", len(comments), q)
+	if err := rows.Err(); err != nil {
+		logging.From(ctx).Error().Err(err).Msg("FindDescendants rows iteration failed")
+		return nil, err
+	}
+
+	logging.From(ctx).Debug().Int("count", len(comments)).Int("roots", len(rootIDs)).Msg("FindDescendants returned comments")
 	return comments, nil
 }
 
-func (r *commentRepository) searchFallback(ctx context.Context, q string, limit, offset int) ([]*domain.Comment, error) {
-	query := `
-		SELECT id, parent_id, author, content, created_at, updated_at, deleted
-		FROM comments
-		WHERE (content ILIKE '%' || $1 || '%' OR author ILIKE '%' || $1 || '%')
-		AND deleted = false
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
-	`
+func (r *commentRepository) Update(ctx context.Context, c *domain.Comment) error {
+	return r.WithinTx(ctx, func(ctx context.Context) error {
+		var previous string
+		var deleted bool
+		err := r.master(ctx).QueryRowContext(ctx, `
+			SELECT content, deleted
+			FROM comments
+			WHERE id = $1
+			FOR UPDATE
+		`, c.ID).Scan(&previous, &deleted)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("comment %d: %w", c.ID, domain.ErrNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		if deleted {
+			return fmt.Errorf("comment %d is deleted: %w", c.ID, domain.ErrConflict)
+		}
 
-	zlog.Logger.Info().Msgf("Using fallback search for query: %s", q)
+		now := time.Now()
+
+		if previous != c.Content {
+			if _, err := r.exec(ctx, `
+				INSERT INTO comment_revisions (comment_id, content, edited_at)
+				VALUES ($1, $2, $3)
+			`, c.ID, previous, now); err != nil {
+				logging.From(ctx).Error().Err(err).Msg("Update: saving revision failed")
+				return err
+			}
+		}
 
-	rows, err := r.db.QueryWithRetry(ctx, r.strategy, query, q, limit, offset)
+		updated, err := scanComment(r.master(ctx).QueryRowContext(ctx, `
+			UPDATE comments
+			SET content = $2, updated_at = $3
+			WHERE id = $1
+			RETURNING `+commentColumns, c.ID, c.Content, now))
+		if err != nil {
+			logging.From(ctx).Error().Err(err).Msg("Update failed")
+			return err
+		}
+
+		*c = *updated
+		return nil
+	})
+}
+
+func (r *commentRepository) FindRevisions(ctx context.Context, commentID int64) ([]*domain.Revision, error) {
+	rows, err := r.query(ctx, `
+		SELECT id, comment_id, content, edited_at
+		FROM comment_revisions
+		WHERE comment_id = $1
+		ORDER BY edited_at DESC, id DESC
+	`, commentID)
 	if err != nil {
-		zlog.Logger.Error().Err(err).Msg("Fallback search failed")
+		logging.From(ctx).Error().Err(err).Msg("FindRevisions query failed")
 		return nil, err
 	}
 	defer rows.Close()
 
-	var comments []*domain.Comment
+	var revisions []*domain.Revision
 	for rows.Next() {
-		c := &domain.Comment{}
-		var parent sql.NullInt64
-		var updated sql.NullTime
-
-		if err := rows.Scan(
-			&c.ID,
-			&parent,
-			&c.Author,
-			&c.Content,
-			&c.CreatedAt,
-			&updated,
-			&c.Deleted,
-		); err != nil {
+		rev := &domain.Revision{}
+		if err := rows.Scan(&rev.ID, &rev.CommentID, &rev.Content, &rev.EditedAt); err != nil {
+			logging.From(ctx).Error().Err(err).Msg("FindRevisions scan failed")
 			return nil, err
 		}
+		revisions = append(revisions, rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		logging.From(ctx).Error().Err(err).Msg("FindRevisions rows iteration failed")
+		return nil, err
+	}
 
-		if parent.Valid {
-			c.ParentID = &parent.Int64
+	return revisions, nil
+}
+
+func (r *commentRepository) Delete(ctx context.Context, id int64, mode domain.DeleteMode) (int64, error) {
+	var affected int64
+	err := r.WithinTx(ctx, func(ctx context.Context) error {
+		locked, err := r.lockAncestors(ctx, id)
+		if err != nil {
+			return err
 		}
-		if updated.Valid {
-			c.UpdatedAt = &updated.Time
+		if locked == 0 {
+			return fmt.Errorf("comment %d: %w", id, domain.ErrNotFound)
 		}
 
-		comments = append(comments, c)
+		var deleted bool
+		err = r.master(ctx).QueryRowContext(ctx,
+			`SELECT deleted FROM comments WHERE id = $1 FOR UPDATE`, id,
+		).Scan(&deleted)
+		if err != nil {
+			return err
+		}
+
+		switch mode {
+		case domain.DeletePurge:
+			affected, err = r.purgeSubtree(ctx, id)
+		case domain.DeleteSubtree:
+			affected, err = r.softDeleteSubtree(ctx, id)
+		default:
+			if deleted {
+				return fmt.Errorf("comment %d is already deleted: %w", id, domain.ErrConflict)
+			}
+			affected, err = r.softDelete(ctx, id)
+		}
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			return fmt.Errorf("comment %d and its replies are already deleted: %w", id, domain.ErrConflict)
+		}
+		return nil
+	})
+	return affected, err
+}
+
+func (r *commentRepository) softDelete(ctx context.Context, id int64) (int64, error) {
+	res, err := r.exec(ctx, `
+		UPDATE comments
+		SET deleted = true, updated_at = $2, deleted_at = $2
+		WHERE id = $1 AND deleted = false
+	`, id, time.Now())
+	if err != nil {
+		return 0, err
 	}
+	return res.RowsAffected()
+}
 
-	zlog.Logger.Info().Msgf("Fallback search returned %d comments", len(comments))
-	return comments, nil
+func (r *commentRepository) softDeleteSubtree(ctx context.Context, id int64) (int64, error) {
+	res, err := r.exec(ctx, `
+		WITH RECURSIVE sub AS (
+			SELECT id FROM comments WHERE id = $1
+			UNION ALL
+			SELECT c.id FROM comments c JOIN sub s ON c.parent_id = s.id
+		)
+		UPDATE comments
+		SET deleted = true, updated_at = $2, deleted_at = $2
+		WHERE id IN (SELECT id FROM sub) AND deleted = false
+	`, id, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// purgeSubtree безвозвратно удаляет комментарий. Ответы удаляет
+// ON DELETE CASCADE, поэтому размер поддерева считается заранее.
+func (r *commentRepository) purgeSubtree(ctx context.Context, id int64) (int64, error) {
+	var count int64
+	err := r.master(ctx).QueryRowContext(ctx, `
+		WITH RECURSIVE sub AS (
+			SELECT id FROM comments WHERE id = $1
+			UNION ALL
+			SELECT c.id FROM comments c JOIN sub s ON c.parent_id = s.id
+		)
+		SELECT count(*) FROM sub
+	`, id).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := r.exec(ctx, `DELETE FROM comments WHERE id = $1`, id); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *commentRepository) Restore(ctx context.Context, id int64, subtree bool, restoredBy string) (int64, error) {
+	var affected int64
+	err := r.WithinTx(ctx, func(ctx context.Context) error {
+		locked, err := r.lockAncestors(ctx, id)
+		if err != nil {
+			return err
+		}
+		if locked == 0 {
+			return fmt.Errorf("comment %d: %w", id, domain.ErrNotFound)
+		}
+
+		var deleted bool
+		err = r.master(ctx).QueryRowContext(ctx,
+			`SELECT deleted FROM comments WHERE id = $1 FOR UPDATE`, id,
+		).Scan(&deleted)
+		if err != nil {
+			return err
+		}
+
+		scope := `SELECT $1::bigint AS id`
+		if subtree {
+			scope = `
+				WITH RECURSIVE sub AS (
+					SELECT id FROM comments WHERE id = $1
+					UNION ALL
+					SELECT c.id FROM comments c JOIN sub s ON c.parent_id = s.id
+				)
+				SELECT id FROM sub`
+		}
+
+		res, err := r.exec(ctx, `
+			UPDATE comments
+			SET deleted = false, deleted_at = NULL, restored_at = $2, restored_by = $3
+			WHERE id IN (`+scope+`) AND deleted = true
+		`, id, time.Now(), restoredBy)
+		if err != nil {
+			return err
+		}
+		if affected, err = res.RowsAffected(); err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			return fmt.Errorf("comment %d is not deleted: %w", id, domain.ErrConflict)
+		}
+		return nil
+	})
+	return affected, err
+}
+
+func (r *commentRepository) SetLocked(ctx context.Context, id int64, locked bool) (*domain.Comment, error) {
+	c, err := scanComment(r.master(ctx).QueryRowContext(ctx, `
+		UPDATE comments
+		SET locked = $2
+		WHERE id = $1
+		RETURNING `+commentColumns, id, locked))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("comment %d: %w", id, domain.ErrNotFound)
+		}
+		logging.From(ctx).Error().Err(err).Msg("SetLocked failed")
+		return nil, err
+	}
+	return c, nil
+}
+
+// PurgeDeleted безвозвратно удаляет до limit комментариев, мягко удалённых
+// раньше olderThan и не имеющих ответов. Удаляются только листья: родитель
+// становится листом после удаления детей и попадает в следующий пакет, так
+// что каскад не сотрёт ответы, срок хранения которых ещё не истёк.
+func (r *commentRepository) PurgeDeleted(ctx context.Context, olderThan time.Time, limit int) (int64, error) {
+	res, err := r.exec(ctx, `
+		DELETE FROM comments
+		WHERE id IN (
+			SELECT c.id
+			FROM comments c
+			WHERE c.deleted = true
+			  AND c.deleted_at < $1
+			  AND NOT EXISTS (SELECT 1 FROM comments ch WHERE ch.parent_id = c.id)
+			ORDER BY c.deleted_at
+			LIMIT $2
+		)
+	`, olderThan, limit)
+	if err != nil {
+		logging.From(ctx).Error().Err(err).Msg("PurgeDeleted failed")
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Search ищет по content_tsv через websearch_to_tsquery и упорядочивает
+// выдачу по релевантности. Ищется только текст комментария (миграция
+// 00004): имя автора в поиск не входит ни в одной из веток, хотя раньше
+// ILIKE проверял и его. websearch_to_tsquery не падает на синтаксисе, но
+// может дать пустой tsquery (запрос из одной пунктуации) — тогда тем же
+// запросом ищется подстрока через ILIKE. Условие numnode не зависит от
+// строк таблицы, поэтому Postgres вычисляет его один раз и выполняет
+// только одну из веток.
+func (r *commentRepository) Search(ctx context.Context, q string, page domain.Page) ([]*domain.SearchHit, error) {
+	args := []interface{}{q, headlineOptions}
+	where := ""
+	if page.After != nil && page.After.Rank != nil {
+		args = append(args, *page.After.Rank, page.After.CreatedAt, page.After.ID)
+		where = fmt.Sprintf("WHERE (rank, created_at, id) < ($%d::real, $%d, $%d)", len(args)-2, len(args)-1, len(args))
+		page.After = nil
+		page.Offset = 0
+	}
+	keyset, limit := pageClauses(page, "<", &args)
+	if keyset != "" {
+		// курсор без ранга выдан не поиском — листаем по времени создания
+		where = "WHERE " + keyset
+	}
+
+	tq := fmt.Sprintf("websearch_to_tsquery('%s', $1)", searchConfig)
+	query := fmt.Sprintf(`
+		SELECT %[1]s, rank, fallback,
+		       CASE WHEN fallback THEN NULL ELSE ts_headline('%[2]s', content, %[3]s, $2) END AS snippet
+		FROM (
+			SELECT %[1]s, ts_rank(content_tsv, %[3]s) AS rank, false AS fallback
+			FROM comments
+			WHERE numnode(%[3]s) > 0 AND content_tsv @@ %[3]s AND deleted = false
+			UNION ALL
+			SELECT %[1]s, 0::real AS rank, true AS fallback
+			FROM comments
+			WHERE numnode(%[3]s) = 0
+			  AND content ILIKE '%%' || $1 || '%%'
+			  AND deleted = false
+		) matched
+		%[4]s
+		ORDER BY rank DESC, created_at DESC, id DESC
+		%[5]s
+	`, commentColumns, searchConfig, tq, where, limit)
+
+	logging.From(ctx).Info().Str("query", q).Int("limit", page.Limit).Int("offset", page.Offset).Msg("Full-text search")
+
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		logging.From(ctx).Error().Err(err).Msg("Search query failed")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []*domain.SearchHit
+	for rows.Next() {
+		hit := &domain.SearchHit{}
+		var fallback bool
+		var snippet sql.NullString
+		c, err := scanComment(rows, &hit.Rank, &fallback, &snippet)
+		if err != nil {
+			logging.From(ctx).Error().Err(err).Msg("Search scan failed")
+			return nil, err
+		}
+		hit.Comment = c
+		hit.Ranked = !fallback
+		if fallback {
+			hit.Snippet = fallbackSnippet(c.Content, q)
+		} else {
+			hit.Snippet = markHeadline(snippet.String)
+		}
+
+		hits = append(hits, hit)
+	}
+
+	if err := rows.Err(); err != nil {
+		logging.From(ctx).Error().Err(err).Msg("Search rows iteration failed")
+		return nil, err
+	}
+
+	logging.From(ctx).Info().Int("count", len(hits)).Str("query", q).Msg("Full-text search returned comments")
+	return hits, nil
+}
+
+// pageClauses добавляет в args параметры страницы и возвращает keyset-условие
+// (пустое для offset-пагинации) и LIMIT/OFFSET. cmp — ">" для сортировки по
+// возрастанию и "<" по убыванию.
+func pageClauses(page domain.Page, cmp string, args *[]interface{}) (keyset, limit string) {
+	if page.After != nil {
+		*args = append(*args, page.After.CreatedAt, page.After.ID)
+		keyset = fmt.Sprintf("(created_at, id) %s ($%d, $%d)", cmp, len(*args)-1, len(*args))
+	}
+
+	*args = append(*args, page.Limit)
+	limit = fmt.Sprintf("LIMIT $%d", len(*args))
+
+	if page.After == nil {
+		*args = append(*args, page.Offset)
+		limit += fmt.Sprintf(" OFFSET $%d", len(*args))
+	}
+	return keyset, limit
+}
+
+// isForeignKeyViolation сообщает о нарушении внешнего ключа (нет родителя).
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
 }
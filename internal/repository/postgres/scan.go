@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+)
+
+// commentColumns — колонки комментария в порядке, который ожидает scanComment.
+const commentColumns = "id, parent_id, author, content, created_at, updated_at, deleted, locked, restored_at, restored_by, live_descendants, owner"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanComment читает колонки commentColumns и следом за ними extra.
+func scanComment(row rowScanner, extra ...interface{}) (*domain.Comment, error) {
+	c := &domain.Comment{}
+	var parent sql.NullInt64
+	var updated sql.NullTime
+	var restoredAt sql.NullTime
+	var restoredBy sql.NullString
+	var owner sql.NullString
+
+	dest := append([]interface{}{
+		&c.ID,
+		&parent,
+		&c.Author,
+		&c.Content,
+		&c.CreatedAt,
+		&updated,
+		&c.Deleted,
+		&c.Locked,
+		&restoredAt,
+		&restoredBy,
+		&c.LiveDescendants,
+		&owner,
+	}, extra...)
+
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if parent.Valid {
+		c.ParentID = &parent.Int64
+	}
+	if updated.Valid {
+		c.UpdatedAt = &updated.Time
+	}
+	if restoredAt.Valid {
+		c.RestoredAt = &restoredAt.Time
+	}
+	c.RestoredBy = restoredBy.String
+	c.Owner = owner.String
+	return c, nil
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Маркеры совпадений, которые ts_headline вставляет вместо HTML-тегов:
+// текст комментария экранируется уже после выделения, иначе разметка из
+// самого комментария попала бы в сниппет как есть.
+const (
+	markStart = "\x02"
+	markStop  = "\x03"
+
+	headlineOptions = "StartSel=" + markStart + ", StopSel=" + markStop +
+		", MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=\" … \""
+
+	// snippetContext — сколько байт текста оставлять вокруг совпадения в
+	// сниппете запасного поиска.
+	snippetContext = 80
+)
+
+// markHeadline экранирует результат ts_headline и заменяет маркеры на <mark>.
+func markHeadline(raw string) string {
+	escaped := html.EscapeString(raw)
+	escaped = strings.ReplaceAll(escaped, markStart, "<mark>")
+	return strings.ReplaceAll(escaped, markStop, "</mark>")
+}
+
+// fallbackSnippet строит сниппет для ILIKE-поиска: фрагмент вокруг первого
+// совпадения q без учёта регистра.
+func fallbackSnippet(content, q string) string {
+	re, err := regexp.Compile("(?i)" + regexp.QuoteMeta(q))
+	if err != nil {
+		return html.EscapeString(content)
+	}
+
+	loc := re.FindStringIndex(content)
+	if loc == nil {
+		end := min(len(content), 2*snippetContext)
+		for end < len(content) && !utf8.RuneStart(content[end]) {
+			end++
+		}
+		if end < len(content) {
+			return html.EscapeString(content[:end]) + " …"
+		}
+		return html.EscapeString(content)
+	}
+
+	start := max(0, loc[0]-snippetContext)
+	for start > 0 && !utf8.RuneStart(content[start]) {
+		start--
+	}
+	end := min(len(content), loc[1]+snippetContext)
+	for end < len(content) && !utf8.RuneStart(content[end]) {
+		end++
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("… ")
+	}
+	b.WriteString(html.EscapeString(content[start:loc[0]]))
+	b.WriteString("<mark>")
+	b.WriteString(html.EscapeString(content[loc[0]:loc[1]]))
+	b.WriteString("</mark>")
+	b.WriteString(html.EscapeString(content[loc[1]:end]))
+	if end < len(content) {
+		b.WriteString(" …")
+	}
+	return b.String()
+}
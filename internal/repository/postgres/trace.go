@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/yokitheyo/wb_level3_3/internal/tracing"
+)
+
+// tracedExecutor открывает спан на каждый SQL-запрос с его текстом и числом
+// затронутых или прочитанных строк. Спан чтения закрывается, когда строки
+// прочитаны: Close у результата QueryContext или Scan у QueryRowContext.
+type tracedExecutor struct {
+	executor
+	// target — куда ушёл запрос: tx, master или replica.
+	target string
+}
+
+func (r *commentRepository) traced(e executor) conn {
+	target := "replica"
+	switch e := e.(type) {
+	case *sql.Tx:
+		target = "tx"
+	case *sql.DB:
+		if e == r.db.Master {
+			target = "master"
+		}
+	}
+	return tracedExecutor{executor: e, target: target}
+}
+
+func (e tracedExecutor) start(ctx context.Context, query string) (context.Context, trace.Span) {
+	query = strings.TrimSpace(query)
+	op := query
+	if i := strings.IndexAny(op, " \n\t("); i > 0 {
+		op = op[:i]
+	}
+	return tracing.Start(ctx, strings.ToUpper(op),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.statement", query),
+			attribute.String("db.target", e.target),
+		),
+	)
+}
+
+func (e tracedExecutor) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := e.start(ctx, query)
+	res, err := e.executor.ExecContext(ctx, query, args...)
+	if err == nil {
+		if n, nerr := res.RowsAffected(); nerr == nil {
+			span.SetAttributes(attribute.Int64("db.rows", n))
+		}
+	}
+	tracing.End(span, err)
+	return res, err
+}
+
+func (e tracedExecutor) QueryContext(ctx context.Context, query string, args ...interface{}) (resultRows, error) {
+	ctx, span := e.start(ctx, query)
+	rows, err := e.executor.QueryContext(ctx, query, args...)
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+func (e tracedExecutor) QueryRowContext(ctx context.Context, query string, args ...interface{}) rowScanner {
+	ctx, span := e.start(ctx, query)
+	return tracedRow{row: e.executor.QueryRowContext(ctx, query, args...), span: span}
+}
+
+// tracedRows считает прочитанные строки и закрывает спан запроса в Close.
+type tracedRows struct {
+	*sql.Rows
+	span   trace.Span
+	n      int64
+	closed bool
+}
+
+func (r *tracedRows) Next() bool {
+	if !r.Rows.Next() {
+		return false
+	}
+	r.n++
+	return true
+}
+
+func (r *tracedRows) Close() error {
+	err := r.Rows.Close()
+	if !r.closed {
+		r.closed = true
+		spanErr := r.Rows.Err()
+		if spanErr == nil {
+			spanErr = err
+		}
+		r.span.SetAttributes(attribute.Int64("db.rows", r.n))
+		tracing.End(r.span, spanErr)
+	}
+	return err
+}
+
+// tracedRow закрывает спан запроса в Scan, когда строка прочитана.
+type tracedRow struct {
+	row  *sql.Row
+	span trace.Span
+}
+
+func (r tracedRow) Scan(dest ...interface{}) error {
+	err := r.row.Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.span.SetAttributes(attribute.Int64("db.rows", 0))
+		tracing.End(r.span, nil)
+		return err
+	}
+	if err == nil {
+		r.span.SetAttributes(attribute.Int64("db.rows", 1))
+	}
+	tracing.End(r.span, err)
+	return err
+}
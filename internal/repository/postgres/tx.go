@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/yokitheyo/wb_level3_3/internal/retry"
+)
+
+type txKey struct{}
+
+// executor — общее подмножество *sql.DB и *sql.Tx.
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// conn — executor, обёрнутый трассировкой: результаты чтения отдаются
+// интерфейсами, чтобы спан запроса закрывался, когда строки прочитаны.
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (resultRows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) rowScanner
+}
+
+// resultRows — используемая репозиторием часть *sql.Rows.
+type resultRows interface {
+	rowScanner
+	Next() bool
+	Err() error
+	Close() error
+}
+
+// WithinTx выполняет fn в транзакции на master. Все методы репозитория,
+// вызванные с переданным в fn контекстом, работают в этой транзакции.
+// Вложенный вызов переиспользует уже открытую транзакцию.
+func (r *commentRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := txFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func txFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	return tx, ok
+}
+
+// master возвращает текущую транзакцию или master-подключение.
+func (r *commentRepository) master(ctx context.Context) conn {
+	if tx, ok := txFromContext(ctx); ok {
+		return r.traced(tx)
+	}
+	return r.traced(r.db.Master)
+}
+
+// reader возвращает текущую транзакцию, а вне её — подключение для чтения,
+// выбранное ReplicaSet (реплику или master).
+func (r *commentRepository) reader(ctx context.Context) conn {
+	if tx, ok := txFromContext(ctx); ok {
+		return r.traced(tx)
+	}
+	return r.traced(r.replicas.Reader(ctx))
+}
+
+// query выполняет чтение в текущей транзакции, а вне её — на реплике с
+// повторами; каждая попытка заново выбирает подключение, так что повтор
+// уходит на другую реплику.
+func (r *commentRepository) query(ctx context.Context, query string, args ...interface{}) (resultRows, error) {
+	if tx, ok := txFromContext(ctx); ok {
+		return r.traced(tx).QueryContext(ctx, query, args...)
+	}
+
+	var rows resultRows
+	err := retry.Do(ctx, "db_query", r.strategy, func() error {
+		res, err := r.reader(ctx).QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		rows = res
+		return nil
+	})
+	return rows, err
+}
+
+// exec выполняет изменение в текущей транзакции, а вне её — на master
+// с повторами.
+func (r *commentRepository) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if tx, ok := txFromContext(ctx); ok {
+		return r.traced(tx).ExecContext(ctx, query, args...)
+	}
+
+	var res sql.Result
+	err := retry.Do(ctx, "db_exec", r.strategy, func() error {
+		out, err := r.master(ctx).ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		res = out
+		return nil
+	})
+	return res, err
+}
@@ -0,0 +1,48 @@
+package retry
+
+import (
+	"context"
+	"time"
+
+	"github.com/wb-go/wbf/retry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/yokitheyo/wb_level3_3/internal/metrics"
+)
+
+// Do выполняет fn по стратегии strategy, как retry.Do из wbf, но учитывает
+// каждую попытку в метриках под именем operation (неудачные — ещё и событием
+// в текущем спане), не ждёт после последней попытки и прекращает повторы при
+// отмене ctx.
+func Do(ctx context.Context, operation string, strategy retry.Strategy, fn func() error) error {
+	attempts := max(strategy.Attempts, 1)
+	delay := strategy.Delay
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		err = fn()
+		metrics.RetryAttempt(operation, err)
+		if err == nil {
+			return nil
+		}
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.String("retry.operation", operation),
+			attribute.Int("retry.attempt", i+1),
+			attribute.String("error", err.Error()),
+		))
+		if i == attempts-1 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay = time.Duration(float64(delay) * strategy.Backoff)
+	}
+
+	metrics.RetryExhausted(operation)
+	return err
+}
@@ -0,0 +1,103 @@
+// Package tracing настраивает OpenTelemetry: провайдер трассировки,
+// экспортёр и W3C-пропагацию контекста.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+)
+
+// instrumentationName — имя, под которым сервис создаёт свои спаны.
+const instrumentationName = "github.com/yokitheyo/wb_level3_3"
+
+// Options задаёт экспорт спанов.
+type Options struct {
+	ServiceName string
+	// Exporter — "stdout" (для локальной отладки) или "otlp".
+	Exporter string
+	// OTLPEndpoint — host:port OTLP/HTTP коллектора; пусто — из переменных
+	// окружения OTEL_EXPORTER_OTLP_*.
+	OTLPEndpoint string
+	OTLPInsecure bool
+	// SampleRatio — доля трассируемых корневых запросов, от 0 до 1.
+	SampleRatio float64
+}
+
+// Setup регистрирует глобальный TracerProvider и пропагатор W3C
+// traceparent/baggage. Возвращённая функция сбрасывает буфер спанов и
+// останавливает экспортёр.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch opts.Exporter {
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "otlp":
+		var clientOpts []otlptracehttp.Option
+		if opts.OTLPEndpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpoint(opts.OTLPEndpoint))
+		}
+		if opts.OTLPInsecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, clientOpts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s exporter: %w", opts.Exporter, err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(attribute.String("service.name", opts.ServiceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	return provider.Shutdown, nil
+}
+
+// Start начинает спан сервиса. Пока Setup не вызван, спаны ничего не стоят.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End завершает спан, записывая в него ошибку. Статус Error ставится только
+// сбоям: ошибки предметной области (не найдено, валидация) — нормальный
+// ответ клиенту.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		if !domain.IsExpected(err) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
@@ -2,105 +2,488 @@ package usecase
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"time"
 
-	"github.com/wb-go/wbf/zlog"
+	"github.com/yokitheyo/wb_level3_3/internal/events"
 	"github.com/yokitheyo/wb_level3_3/internal/infrastructure/search"
+	"github.com/yokitheyo/wb_level3_3/internal/logging"
+	"github.com/yokitheyo/wb_level3_3/internal/policy"
+	"github.com/yokitheyo/wb_level3_3/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/yokitheyo/wb_level3_3/internal/domain"
 )
 
+// Options задаёт ограничения usecase. Нулевые значения — без ограничений.
+type Options struct {
+	// MaxDepth — максимальная глубина вложенности ответа; у комментария
+	// верхнего уровня глубина 0.
+	MaxDepth int
+	// MaxContentLength ограничивает длину текста в символах после
+	// нормализации; MaxAuthorLength — длину имени автора, до которой
+	// обрезается subject пользователя.
+	MaxAuthorLength  int
+	MaxContentLength int
+}
+
 type CommentUsecase struct {
 	repo   domain.CommentRepository
 	search search.FullTextSearcher
+	policy *policy.CommentPolicy
+	events events.Publisher
+	opts   Options
 }
 
-func NewCommentUsecase(repo domain.CommentRepository, search search.FullTextSearcher) *CommentUsecase {
+// NewCommentUsecase создаёт usecase. publisher получает события об
+// изменениях комментариев; nil — события не публикуются.
+func NewCommentUsecase(repo domain.CommentRepository, search search.FullTextSearcher, policy *policy.CommentPolicy, publisher events.Publisher, opts Options) *CommentUsecase {
 	return &CommentUsecase{
 		repo:   repo,
 		search: search,
+		policy: policy,
+		events: publisher,
+		opts:   opts,
 	}
 }
 
-func (u *CommentUsecase) CreateComment(ctx context.Context, parentID *int64, author, content string) (*domain.Comment, error) {
-	if author == "" {
-		return nil, errors.New("author required")
+func (u *CommentUsecase) CreateComment(ctx context.Context, parentID *int64, content string) (_ *domain.Comment, err error) {
+	ctx, span := tracing.Start(ctx, "CommentUsecase.CreateComment")
+	defer func() { tracing.End(span, err) }()
+	ctx = logging.WithParentID(ctx, parentID)
+
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		return nil, err
 	}
-	if content == "" {
-		return nil, errors.New("content required")
+	author, err := authorName(principal.Subject, u.opts.MaxAuthorLength)
+	if err != nil {
+		return nil, err
+	}
+	content = normalizeText(content, true)
+
+	verr := &domain.ValidationError{}
+	checkText(verr, "content", content, u.opts.MaxContentLength)
+	if parentID != nil && *parentID <= 0 {
+		verr.Add("parent_id", "must be positive")
+	}
+	if err := verr.OrNil(); err != nil {
+		return nil, err
 	}
 
 	c := &domain.Comment{
 		ParentID: parentID,
 		Author:   author,
+		Owner:    principal.Subject,
 		Content:  content,
 	}
 
-	if err := u.repo.Save(ctx, c); err != nil {
-		zlog.Logger.Error().Err(err).Msg("usecase: Save comment failed")
+	var threadID int64
+	err = u.repo.WithinTx(ctx, func(ctx context.Context) error {
+		if parentID != nil {
+			if err := u.checkParent(ctx, *parentID); err != nil {
+				return err
+			}
+			if threadID, err = u.repo.FindRootID(ctx, *parentID); err != nil {
+				return err
+			}
+		}
+		return u.repo.Save(ctx, c)
+	})
+	if err != nil {
+		logFailure(ctx, err, "usecase: Save comment failed")
 		return nil, err
 	}
+	if parentID == nil {
+		threadID = c.ID
+	}
 
-	zlog.Logger.Info().Msgf("comment created id=%d parent=%v", c.ID, c.ParentID)
+	logging.From(ctx).Info().Int64("comment_id", c.ID).Msg("comment created")
+	u.publish(ctx, events.Event{
+		Type:      events.CommentCreated,
+		ThreadID:  threadID,
+		CommentID: c.ID,
+		ParentID:  c.ParentID,
+		Comment:   c,
+	})
 	return c, nil
 }
 
-func (u *CommentUsecase) GetThread(ctx context.Context, parentID *int64, limit, offset int, sort string) ([]*domain.Comment, error) {
-	comments, err := u.repo.FindChildren(ctx, parentID, limit, offset, sort)
+// checkParent проверяет в текущей транзакции, что на комментарий parentID
+// можно ответить: он существует, не удалён, не закрыт и ответ не превысит
+// допустимую глубину.
+func (u *CommentUsecase) checkParent(ctx context.Context, parentID int64) error {
+	parent, err := u.repo.LockForReply(ctx, parentID)
 	if err != nil {
-		zlog.Logger.Error().Err(err).Msg("usecase: FindChildren failed")
-		return nil, err
+		return err
+	}
+	if parent.Deleted {
+		return fmt.Errorf("reply to comment %d: %w", parentID, domain.ErrParentDeleted)
+	}
+	if parent.Locked {
+		return fmt.Errorf("reply to comment %d: %w", parentID, domain.ErrParentLocked)
+	}
+	if u.opts.MaxDepth > 0 && parent.Depth+1 > u.opts.MaxDepth {
+		return domain.NewValidationError("parent_id",
+			fmt.Sprintf("maximum nesting depth %d exceeded", u.opts.MaxDepth))
 	}
+	return nil
+}
 
-	zlog.Logger.Info().Msgf("GetThread found %d comments for parent_id=%v", len(comments), parentID)
+func (u *CommentUsecase) GetThread(ctx context.Context, parentID *int64, page domain.Page) (_ []*domain.Comment, _ *domain.Cursor, err error) {
+	ctx, span := tracing.Start(ctx, "CommentUsecase.GetThread")
+	defer func() { tracing.End(span, err) }()
+	ctx = logging.WithParentID(ctx, parentID)
 
-	// 🚀 Всегда рекурсивно достраиваем дерево, независимо от уровня
-	for _, comment := range comments {
-		if err := u.loadAllChildren(ctx, comment); err != nil {
-			zlog.Logger.Error().Err(err).Msgf("failed to load children for comment %d", comment.ID)
-		}
+	comments, err := u.repo.FindChildren(ctx, parentID, page)
+	if err != nil {
+		logging.From(ctx).Error().Err(err).Msg("usecase: FindChildren failed")
+		return nil, nil, err
 	}
 
-	return comments, nil
+	logging.From(ctx).Info().Int("count", len(comments)).Msg("GetThread found comments")
+
+	if err := u.loadTrees(ctx, comments, 0); err != nil {
+		logging.From(ctx).Error().Err(err).Msg("usecase: FindDescendants failed")
+		return nil, nil, err
+	}
+
+	return comments, domain.NextCursor(comments, page.Limit), nil
 }
 
-// loadAllChildren рекурсивно загружает всех детей для комментария
-func (u *CommentUsecase) loadAllChildren(ctx context.Context, comment *domain.Comment) error {
-	// Загружаем всех прямых детей (без лимита для полного дерева)
-	children, err := u.repo.FindChildren(ctx, &comment.ID, 1000, 0, "asc") // Увеличиваем лимит
+// loadTrees одним запросом загружает поддеревья для roots и раскладывает
+// потомков по Children. maxDepth <= 0 — без ограничения глубины.
+func (u *CommentUsecase) loadTrees(ctx context.Context, roots []*domain.Comment, maxDepth int) error {
+	if len(roots) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(roots))
+	for _, root := range roots {
+		ids = append(ids, root.ID)
+	}
+
+	descendants, err := u.repo.FindDescendants(ctx, ids, maxDepth)
 	if err != nil {
 		return err
 	}
 
-	comment.Children = children
-	zlog.Logger.Debug().Msgf("loaded %d children for comment %d", len(children), comment.ID)
+	buildTree(roots, descendants)
+	return nil
+}
 
-	// Рекурсивно загружаем детей для каждого ребенка
-	for _, child := range children {
-		if err := u.loadAllChildren(ctx, child); err != nil {
-			zlog.Logger.Error().Err(err).Msgf("failed to load children for comment %d", child.ID)
-			// Продолжаем обработку остальных детей
+// buildTree раскладывает плоский список потомков по родителям и превращает
+// удалённые узлы в надгробия. Потомки должны идти по возрастанию глубины,
+// тогда родитель всегда встречается раньше своих детей и порядок среди
+// соседей сохраняется.
+func buildTree(roots []*domain.Comment, descendants []*domain.Comment) {
+	byID := make(map[int64]*domain.Comment, len(roots)+len(descendants))
+	for _, root := range roots {
+		root.Tombstone()
+		root.Children = nil
+		byID[root.ID] = root
+	}
+
+	for _, c := range descendants {
+		c.Tombstone()
+		if c.ParentID == nil {
+			continue
+		}
+		parent, ok := byID[*c.ParentID]
+		if !ok {
+			continue
 		}
+		parent.Children = append(parent.Children, c)
+		byID[c.ID] = c
 	}
+}
 
-	return nil
+func (u *CommentUsecase) GetComment(ctx context.Context, id int64, depth int) (_ *domain.Comment, err error) {
+	ctx, span := tracing.Start(ctx, "CommentUsecase.GetComment", trace.WithAttributes(attribute.Int64("comment.id", id)))
+	defer func() { tracing.End(span, err) }()
+	ctx = logging.WithCommentID(ctx, id)
+
+	if id <= 0 {
+		return nil, domain.NewValidationError("id", "must be positive")
+	}
+
+	c, err := u.repo.FindByID(ctx, id)
+	if err != nil {
+		logFailure(ctx, err, "usecase: FindByID failed")
+		return nil, err
+	}
+	if c.Deleted && c.LiveDescendants == 0 {
+		return nil, fmt.Errorf("comment %d: %w", id, domain.ErrNotFound)
+	}
+	c.Tombstone()
+	if depth <= 0 {
+		return c, nil
+	}
+
+	if err := u.loadTrees(ctx, []*domain.Comment{c}, depth); err != nil {
+		logging.From(ctx).Error().Err(err).Msg("usecase: FindDescendants failed")
+		return nil, err
+	}
+
+	return c, nil
 }
 
-func (u *CommentUsecase) DeleteThread(ctx context.Context, id int64) error {
+func (u *CommentUsecase) EditComment(ctx context.Context, id int64, content string) (_ *domain.Comment, err error) {
+	ctx, span := tracing.Start(ctx, "CommentUsecase.EditComment", trace.WithAttributes(attribute.Int64("comment.id", id)))
+	defer func() { tracing.End(span, err) }()
+	ctx = logging.WithCommentID(ctx, id)
+
 	if id <= 0 {
-		return errors.New("invalid id")
+		return nil, domain.NewValidationError("id", "must be positive")
+	}
+	content = normalizeText(content, true)
+	verr := &domain.ValidationError{}
+	checkText(verr, "content", content, u.opts.MaxContentLength)
+	if err := verr.OrNil(); err != nil {
+		return nil, err
+	}
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := u.repo.FindByID(ctx, id)
+	if err != nil {
+		logFailure(ctx, err, "usecase: FindByID failed")
+		return nil, err
+	}
+	if err := u.policy.CanEdit(principal, c); err != nil {
+		logFailure(ctx, err, "usecase: edit denied")
+		return nil, err
+	}
+	if c.Deleted {
+		return nil, fmt.Errorf("comment %d is deleted: %w", id, domain.ErrConflict)
 	}
-	if err := u.repo.Delete(ctx, id); err != nil {
-		zlog.Logger.Error().Err(err).Msgf("usecase: Delete failed id=%d", id)
+
+	c.Content = content
+	if err := u.repo.Update(ctx, c); err != nil {
+		logFailure(ctx, err, "usecase: Update failed")
+		return nil, err
+	}
+
+	logging.From(ctx).Info().Msg("comment edited")
+	if threadID, err := u.repo.FindRootID(ctx, id); err != nil {
+		logFailure(ctx, err, "usecase: edit event not published")
+	} else {
+		u.publish(ctx, events.Event{
+			Type:      events.CommentEdited,
+			ThreadID:  threadID,
+			CommentID: id,
+			ParentID:  c.ParentID,
+			Comment:   c,
+		})
+	}
+	return c, nil
+}
+
+func (u *CommentUsecase) GetRevisions(ctx context.Context, id int64) (_ []*domain.Revision, err error) {
+	ctx, span := tracing.Start(ctx, "CommentUsecase.GetRevisions", trace.WithAttributes(attribute.Int64("comment.id", id)))
+	defer func() { tracing.End(span, err) }()
+	ctx = logging.WithCommentID(ctx, id)
+
+	if id <= 0 {
+		return nil, domain.NewValidationError("id", "must be positive")
+	}
+
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := u.repo.FindByID(ctx, id)
+	if err != nil {
+		logFailure(ctx, err, "usecase: FindByID failed")
+		return nil, err
+	}
+	if err := u.policy.CanViewRevisions(principal, c); err != nil {
+		logFailure(ctx, err, "usecase: revisions denied")
+		return nil, err
+	}
+
+	return u.repo.FindRevisions(ctx, id)
+}
+
+func (u *CommentUsecase) DeleteThread(ctx context.Context, id int64, mode domain.DeleteMode) (_ int64, err error) {
+	ctx, span := tracing.Start(ctx, "CommentUsecase.DeleteThread", trace.WithAttributes(attribute.Int64("comment.id", id)))
+	defer func() { tracing.End(span, err) }()
+	ctx = logging.WithCommentID(ctx, id)
+
+	if id <= 0 {
+		return 0, domain.NewValidationError("id", "must be positive")
+	}
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		affected int64
+		threadID int64
+		parentID *int64
+	)
+	err = u.repo.WithinTx(ctx, func(ctx context.Context) error {
+		c, err := u.repo.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := u.policy.CanDelete(principal, c, mode); err != nil {
+			return err
+		}
+		// Тред определяется до удаления: после purge комментария уже нет.
+		if threadID, err = u.repo.FindRootID(ctx, id); err != nil {
+			return err
+		}
+		parentID = c.ParentID
+		affected, err = u.repo.Delete(ctx, id, mode)
 		return err
+	})
+	if err != nil {
+		logFailure(ctx, err, "usecase: Delete failed")
+		return 0, err
 	}
-	zlog.Logger.Info().Msgf("comment deleted id=%d", id)
-	return nil
+
+	logging.From(ctx).Info().Str("mode", string(mode)).Int64("affected", affected).Msg("comment deleted")
+	u.publish(ctx, events.Event{
+		Type:      events.CommentDeleted,
+		ThreadID:  threadID,
+		CommentID: id,
+		ParentID:  parentID,
+		Mode:      mode,
+		Affected:  affected,
+	})
+	return affected, nil
+}
+
+func (u *CommentUsecase) RestoreComment(ctx context.Context, id int64, subtree bool) (_ int64, err error) {
+	ctx, span := tracing.Start(ctx, "CommentUsecase.RestoreComment", trace.WithAttributes(attribute.Int64("comment.id", id)))
+	defer func() { tracing.End(span, err) }()
+	ctx = logging.WithCommentID(ctx, id)
+
+	if id <= 0 {
+		return 0, domain.NewValidationError("id", "must be positive")
+	}
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := u.policy.CanRestore(principal, id); err != nil {
+		logFailure(ctx, err, "usecase: restore denied")
+		return 0, err
+	}
+	restoredBy := principal.Subject
+
+	var (
+		affected int64
+		threadID int64
+		restored *domain.Comment
+	)
+	err = u.repo.WithinTx(ctx, func(ctx context.Context) error {
+		if affected, err = u.repo.Restore(ctx, id, subtree, restoredBy); err != nil {
+			return err
+		}
+		if threadID, err = u.repo.FindRootID(ctx, id); err != nil {
+			return err
+		}
+		restored, err = u.repo.FindByID(ctx, id)
+		return err
+	})
+	if err != nil {
+		logFailure(ctx, err, "usecase: Restore failed")
+		return 0, err
+	}
+
+	logging.From(ctx).Info().Bool("subtree", subtree).Int64("affected", affected).Str("restored_by", restoredBy).Msg("comment restored")
+	u.publish(ctx, events.Event{
+		Type:      events.CommentRestored,
+		ThreadID:  threadID,
+		CommentID: id,
+		ParentID:  restored.ParentID,
+		Comment:   restored,
+		Affected:  affected,
+	})
+	return affected, nil
+}
+
+func (u *CommentUsecase) LockComment(ctx context.Context, id int64, locked bool) (_ *domain.Comment, err error) {
+	ctx, span := tracing.Start(ctx, "CommentUsecase.LockComment", trace.WithAttributes(attribute.Int64("comment.id", id)))
+	defer func() { tracing.End(span, err) }()
+	ctx = logging.WithCommentID(ctx, id)
+
+	if id <= 0 {
+		return nil, domain.NewValidationError("id", "must be positive")
+	}
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.policy.CanLock(principal, id); err != nil {
+		logFailure(ctx, err, "usecase: lock denied")
+		return nil, err
+	}
+
+	c, err := u.repo.SetLocked(ctx, id, locked)
+	if err != nil {
+		logFailure(ctx, err, "usecase: SetLocked failed")
+		return nil, err
+	}
+	c.Tombstone()
+
+	logging.From(ctx).Info().Bool("locked", locked).Str("by", principal.Subject).Msg("comment lock changed")
+	return c, nil
 }
 
-func (u *CommentUsecase) SearchComment(ctx context.Context, q string, limit, offset int) ([]*domain.Comment, error) {
+func (u *CommentUsecase) SearchComment(ctx context.Context, q string, page domain.Page) (_ []*domain.SearchHit, _ *domain.Cursor, err error) {
+	ctx, span := tracing.Start(ctx, "CommentUsecase.SearchComment")
+	defer func() { tracing.End(span, err) }()
+
 	if q == "" {
-		return nil, errors.New("empty query")
+		return nil, nil, domain.NewValidationError("query", "required")
+	}
+
+	hits, err := u.search.SearchComments(ctx, q, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	return hits, domain.NextSearchCursor(hits, page.Limit), nil
+}
+
+// publish отправляет событие подписчикам, если издатель задан.
+func (u *CommentUsecase) publish(ctx context.Context, e events.Event) {
+	if u.events == nil {
+		return
+	}
+	// Подписчики читают комментарий из других горутин, поэтому отдаём копию.
+	if e.Comment != nil {
+		c := *e.Comment
+		e.Comment = &c
+	}
+	e.At = time.Now()
+	u.events.Publish(ctx, e)
+}
+
+// currentPrincipal возвращает пользователя, от имени которого выполняется
+// запрос. Без него изменяющие операции недоступны.
+func currentPrincipal(ctx context.Context) (*domain.Principal, error) {
+	p, ok := domain.PrincipalFromContext(ctx)
+	if !ok || p.Subject == "" {
+		return nil, domain.ErrUnauthorized
+	}
+	return p, nil
+}
+
+// logFailure пишет в лог ошибку репозитория. Ожидаемые ошибки предметной
+// области (не найдено, конфликт и т.п.) — ошибки клиента, а не сбои,
+// поэтому логируются уровнем ниже.
+func logFailure(ctx context.Context, err error, msg string) {
+	if domain.IsExpected(err) {
+		logging.From(ctx).Warn().Err(err).Msg(msg)
+		return
 	}
-	return u.search.SearchComments(ctx, q, limit, offset)
+	logging.From(ctx).Error().Err(err).Msg(msg)
 }
@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+)
+
+// normalizeText приводит пользовательский текст к каноническому виду:
+// NFC-нормализация, единые переводы строк, удаление управляющих символов
+// (кроме перевода строки и табуляции) и пробелов по краям. Из невидимых
+// форматирующих символов оставляется только ZWJ, нужный для эмодзи.
+func normalizeText(s string, multiline bool) string {
+	s = norm.NFC.String(s)
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			if multiline {
+				return r
+			}
+			return ' '
+		case r == utf8.RuneError, unicode.IsControl(r), unicode.Is(unicode.Cf, r) && r != '\u200d':
+			return -1
+		}
+		return r
+	}, s)
+
+	return strings.TrimSpace(s)
+}
+
+// authorName строит отображаемое имя автора из subject пользователя. Subject
+// задаёт не клиент, а аутентификация, поэтому слишком длинное имя не ошибка
+// запроса, а обрезается до maxLen символов. Пустое после нормализации имя
+// значит, что subject непригоден, и запрос не аутентифицирован.
+func authorName(subject string, maxLen int) (string, error) {
+	name := normalizeText(subject, false)
+	if name == "" {
+		return "", domain.ErrUnauthorized
+	}
+	if maxLen > 0 && utf8.RuneCountInString(name) > maxLen {
+		name = strings.TrimSpace(string([]rune(name)[:maxLen]))
+	}
+	return name, nil
+}
+
+// checkText проверяет обязательное поле и его длину в символах.
+func checkText(verr *domain.ValidationError, field, value string, maxLen int) {
+	if value == "" {
+		verr.Add(field, "required")
+		return
+	}
+	if maxLen > 0 && utf8.RuneCountInString(value) > maxLen {
+		verr.Add(field, fmt.Sprintf("must be at most %d characters", maxLen))
+	}
+}
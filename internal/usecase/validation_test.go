@@ -0,0 +1,94 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		multiline bool
+		want      string
+	}{
+		{"NFC composes combining marks", "Cafe\u0301", false, "Caf\u00e9"},
+		{"NFC keeps precomposed", "Caf\u00e9", false, "Caf\u00e9"},
+		{"trims spaces", "  hello \t", false, "hello"},
+		{"whitespace only", " \t\r\n ", true, ""},
+		{"CRLF to LF", "a\r\nb", true, "a\nb"},
+		{"newline kept when multiline", "a\nb\tc", true, "a\nb\tc"},
+		{"newline flattened when single line", "a\nb\tc", false, "a b c"},
+		{"control characters dropped", "a\x00b\x07c\u009f", false, "abc"},
+		{"format characters dropped", "a\u200bb\u202ec\ufeff", false, "abc"},
+		{"ZWJ kept for emoji", "\U0001F469\u200d\U0001F4BB", false, "\U0001F469\u200d\U0001F4BB"},
+		{"invalid UTF-8 dropped", "a\xffb", false, "ab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeText(tt.in, tt.multiline); got != tt.want {
+				t.Errorf("normalizeText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckText(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		maxLen  int
+		wantMsg string
+	}{
+		{"empty", "", 10, "required"},
+		{"ASCII at limit", "abcde", 5, ""},
+		{"ASCII over limit", "abcdef", 5, "must be at most 5 characters"},
+		// 5 символов кириллицы — 10 байт: предел считается в символах.
+		{"multibyte at limit", "приве", 5, ""},
+		{"multibyte over limit", "привет", 5, "must be at most 5 characters"},
+		{"emoji counted as runes", "👍👍👍", 3, ""},
+		{"no limit", "long enough", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verr := &domain.ValidationError{}
+			checkText(verr, "content", tt.value, tt.maxLen)
+
+			if tt.wantMsg == "" {
+				if err := verr.OrNil(); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if len(verr.Fields) != 1 || verr.Fields[0].Field != "content" || verr.Fields[0].Message != tt.wantMsg {
+				t.Errorf("got %+v, want content: %q", verr.Fields, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestAuthorName(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		maxLen  int
+		want    string
+		wantErr error
+	}{
+		{"plain", "alice", 100, "alice", nil},
+		{"normalized", " Jose\u0301\n", 100, "Jos\u00e9", nil},
+		{"truncated by runes", "алиса-модератор", 5, "алиса", nil},
+		{"trailing space after truncation", "ab cd", 3, "ab", nil},
+		{"unusable subject", "\u200b\x00 ", 100, "", domain.ErrUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := authorName(tt.subject, tt.maxLen)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("authorName(%q) = (%q, %v), want (%q, %v)", tt.subject, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/wb-go/wbf/zlog"
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+)
+
+// Purger периодически безвозвратно удаляет мягко удалённые комментарии,
+// срок хранения которых истёк.
+type Purger struct {
+	repo      domain.CommentRepository
+	interval  time.Duration
+	retention time.Duration
+	batchSize int
+}
+
+// NewPurger создаёт Purger. Запуск — Run.
+func NewPurger(repo domain.CommentRepository, interval, retention time.Duration, batchSize int) *Purger {
+	return &Purger{
+		repo:      repo,
+		interval:  interval,
+		retention: retention,
+		batchSize: batchSize,
+	}
+}
+
+// Run выполняет очистку каждые interval до отмены ctx. Блокирует вызывающего.
+func (p *Purger) Run(ctx context.Context) {
+	zlog.Logger.Info().
+		Dur("interval", p.interval).
+		Dur("retention", p.retention).
+		Int("batch_size", p.batchSize).
+		Msg("purger started")
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			zlog.Logger.Info().Msg("purger stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purge удаляет пакетами по batchSize, пока очередной пакет не окажется
+// пустым. Неполный пакет не означает конец: PurgeDeleted удаляет только
+// листья, и их удалённые родители становятся листьями лишь для следующего
+// пакета, так что цепочка удалённых комментариев стирается за один запуск.
+func (p *Purger) purge(ctx context.Context) {
+	cutoff := time.Now().Add(-p.retention)
+
+	var total int64
+	for ctx.Err() == nil {
+		n, err := p.repo.PurgeDeleted(ctx, cutoff, p.batchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				zlog.Logger.Error().Err(err).Msg("purger: batch failed")
+			}
+			break
+		}
+		total += n
+		if n == 0 {
+			break
+		}
+	}
+
+	if total > 0 {
+		zlog.Logger.Info().Int64("purged", total).Time("cutoff", cutoff).Msg("purger: deleted comments erased")
+	}
+}
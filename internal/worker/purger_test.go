@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yokitheyo/wb_level3_3/internal/domain"
+)
+
+type purgeReply struct {
+	n   int64
+	err error
+}
+
+// fakeRepo отдаёт заранее заданные ответы PurgeDeleted и запоминает
+// аргументы вызовов. Семантика удаления здесь не моделируется: тест
+// проверяет только цикл purge.
+type fakeRepo struct {
+	domain.CommentRepository
+	replies []purgeReply
+	calls   int
+	cutoffs []time.Time
+	limits  []int
+	// onCall, если задан, вызывается с номером вызова (с 1).
+	onCall func(call int)
+}
+
+func (r *fakeRepo) PurgeDeleted(_ context.Context, olderThan time.Time, limit int) (int64, error) {
+	r.cutoffs = append(r.cutoffs, olderThan)
+	r.limits = append(r.limits, limit)
+	r.calls++
+	if r.onCall != nil {
+		r.onCall(r.calls)
+	}
+	if r.calls > len(r.replies) {
+		return 0, nil
+	}
+	reply := r.replies[r.calls-1]
+	return reply.n, reply.err
+}
+
+func TestPurgeLoop(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	tests := []struct {
+		name      string
+		replies   []purgeReply
+		wantCalls int
+	}{
+		{"nothing to purge", []purgeReply{{0, nil}}, 1},
+		{"full batches until empty", []purgeReply{{10, nil}, {10, nil}, {0, nil}}, 3},
+		{"partial batch does not stop", []purgeReply{{3, nil}, {1, nil}, {0, nil}}, 3},
+		{"error stops", []purgeReply{{10, nil}, {0, errBoom}, {10, nil}}, 2},
+		{"error on first call", []purgeReply{{0, errBoom}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepo{replies: tt.replies}
+			retention := 24 * time.Hour
+			before := time.Now().Add(-retention)
+			NewPurger(repo, time.Hour, retention, 10).purge(context.Background())
+			after := time.Now().Add(-retention)
+
+			if repo.calls != tt.wantCalls {
+				t.Fatalf("PurgeDeleted called %d times, want %d", repo.calls, tt.wantCalls)
+			}
+			for i := range repo.calls {
+				if repo.limits[i] != 10 {
+					t.Errorf("call %d: limit %d, want 10", i, repo.limits[i])
+				}
+				if !repo.cutoffs[i].Equal(repo.cutoffs[0]) {
+					t.Errorf("call %d: cutoff changed within one run", i)
+				}
+			}
+			if c := repo.cutoffs[0]; c.Before(before) || c.After(after) {
+				t.Errorf("cutoff %v not within now-retention [%v, %v]", c, before, after)
+			}
+		})
+	}
+}
+
+func TestPurgeStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	repo := &fakeRepo{replies: []purgeReply{{10, nil}, {10, nil}, {10, nil}, {0, nil}}}
+	repo.onCall = func(call int) {
+		if call == 2 {
+			cancel()
+		}
+	}
+	NewPurger(repo, time.Hour, time.Hour, 10).purge(ctx)
+
+	if repo.calls != 2 {
+		t.Fatalf("PurgeDeleted called %d times, want 2", repo.calls)
+	}
+}
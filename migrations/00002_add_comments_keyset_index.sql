@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX IF NOT EXISTS idx_comments_parent_created_id ON comments(parent_id, created_at, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_comments_parent_created_id;
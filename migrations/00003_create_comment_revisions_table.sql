@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS comment_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    comment_id BIGINT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    edited_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+    );
+
+CREATE INDEX IF NOT EXISTS idx_comment_revisions_comment ON comment_revisions(comment_id, edited_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS comment_revisions;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- content_tsv индексирует только текст комментария: snippet строится
+-- ts_headline по content, и совпадение по другому полю давало бы выдачу без
+-- подсветки.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION comments_content_tsv_update() RETURNS trigger AS $$
+BEGIN
+    NEW.content_tsv := to_tsvector('simple', coalesce(NEW.content, ''));
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DROP TRIGGER IF EXISTS comments_content_tsv_trigger ON comments;
+CREATE TRIGGER comments_content_tsv_trigger
+    BEFORE INSERT OR UPDATE OF content ON comments
+    FOR EACH ROW EXECUTE FUNCTION comments_content_tsv_update();
+
+UPDATE comments SET content_tsv = to_tsvector('simple', coalesce(content, ''));
+
+-- +goose Down
+DROP TRIGGER IF EXISTS comments_content_tsv_trigger ON comments;
+DROP FUNCTION IF EXISTS comments_content_tsv_update();
+UPDATE comments SET content_tsv = NULL;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS restored_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS restored_by TEXT;
+
+-- +goose Down
+ALTER TABLE comments DROP COLUMN IF EXISTS restored_by;
+ALTER TABLE comments DROP COLUMN IF EXISTS restored_at;
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE comments
+SET deleted_at = coalesce(updated_at, created_at)
+WHERE deleted = true AND deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments(deleted_at) WHERE deleted = true;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_comments_deleted_at;
+ALTER TABLE comments DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,73 @@
+-- +goose Up
+-- live_descendants — число неудалённых потомков комментария. Выдача по нему
+-- решает, показывать ли удалённый комментарий надгробием, не обходя
+-- поддерево на каждую строку.
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS live_descendants INTEGER NOT NULL DEFAULT 0;
+
+-- Триггер меняет счётчики предков снизу вверх. LockForReply, Delete и
+-- Restore заранее блокируют всю цепочку предков сверху вниз (lockAncestors в
+-- репозитории), так что триггер обновляет уже заблокированные строки, а
+-- параллельные ответ и удаление поддерева ждут друг друга в одном порядке и
+-- не взаимоблокируются.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION comments_live_descendants_update() RETURNS trigger AS $$
+DECLARE
+    delta  INTEGER := 0;
+    cur_id BIGINT;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        IF NOT NEW.deleted THEN
+            delta := 1;
+        END IF;
+        cur_id := NEW.parent_id;
+    ELSIF TG_OP = 'DELETE' THEN
+        -- Ответы удаляются каскадом уже после родителя, и их обход предков
+        -- обрывается на нём, поэтому родитель снимает и их вклад.
+        delta := -OLD.live_descendants;
+        IF NOT OLD.deleted THEN
+            delta := delta - 1;
+        END IF;
+        cur_id := OLD.parent_id;
+    ELSIF OLD.deleted <> NEW.deleted THEN
+        delta := CASE WHEN NEW.deleted THEN -1 ELSE 1 END;
+        cur_id := NEW.parent_id;
+    END IF;
+
+    IF delta = 0 THEN
+        RETURN NULL;
+    END IF;
+
+    WHILE cur_id IS NOT NULL LOOP
+        UPDATE comments
+        SET live_descendants = live_descendants + delta
+        WHERE id = cur_id
+        RETURNING parent_id INTO cur_id;
+    END LOOP;
+
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DROP TRIGGER IF EXISTS comments_live_descendants_trigger ON comments;
+CREATE TRIGGER comments_live_descendants_trigger
+    AFTER INSERT OR DELETE OR UPDATE OF deleted ON comments
+    FOR EACH ROW EXECUTE FUNCTION comments_live_descendants_update();
+
+WITH RECURSIVE ancestors AS (
+    SELECT parent_id AS id FROM comments WHERE deleted = false AND parent_id IS NOT NULL
+    UNION ALL
+    SELECT c.parent_id
+    FROM comments c
+    JOIN ancestors a ON c.id = a.id
+    WHERE c.parent_id IS NOT NULL
+)
+UPDATE comments
+SET live_descendants = counts.n
+FROM (SELECT id, count(*) AS n FROM ancestors GROUP BY id) counts
+WHERE comments.id = counts.id;
+
+-- +goose Down
+DROP TRIGGER IF EXISTS comments_live_descendants_trigger ON comments;
+DROP FUNCTION IF EXISTS comments_live_descendants_update();
+ALTER TABLE comments DROP COLUMN IF EXISTS live_descendants;
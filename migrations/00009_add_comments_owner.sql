@@ -0,0 +1,8 @@
+-- +goose Up
+-- owner — subject аутентифицированного автора. Заполняется только при
+-- создании комментария; у старых строк его нет, и правами автора на них не
+-- обладает никто.
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS owner TEXT;
+
+-- +goose Down
+ALTER TABLE comments DROP COLUMN IF EXISTS owner;
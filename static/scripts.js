@@ -9,10 +9,13 @@ class CommentTree {
         this.replyToId = null;
         this.hasMore = true;
         this.collapsedComments = new Set();
+        // id тредов (комментариев верхнего уровня) на текущей странице
+        this.visibleThreads = new Set();
 
         this.initElements();
         this.attachEventListeners();
         this.loadComments();
+        this.subscribeUpdates();
     }
 
     initElements() {
@@ -22,7 +25,10 @@ class CommentTree {
         this.clearBtn = document.getElementById('clearBtn');
 
         // Добавление
-        this.authorInput = document.getElementById('authorInput');
+        // Токен живёт только в поле ввода: в localStorage его прочитал бы
+        // любой скрипт страницы. Убираем сохранённый прежними версиями
+        this.tokenInput = document.getElementById('tokenInput');
+        localStorage.removeItem('authToken');
         this.contentInput = document.getElementById('contentInput');
         this.addCommentBtn = document.getElementById('addCommentBtn');
 
@@ -40,7 +46,6 @@ class CommentTree {
         // Модалка
         this.replyModal = document.getElementById('replyModal');
         this.replyTo = document.getElementById('replyTo');
-        this.replyAuthorInput = document.getElementById('replyAuthorInput');
         this.replyContentInput = document.getElementById('replyContentInput');
         this.submitReplyBtn = document.getElementById('submitReplyBtn');
         this.cancelReplyBtn = document.getElementById('cancelReplyBtn');
@@ -73,10 +78,10 @@ class CommentTree {
 
     async apiCall(url, options = {}) {
         try {
-            const res = await fetch(url, {
-                headers: { 'Content-Type': 'application/json' },
-                ...options
-            });
+            const headers = { 'Content-Type': 'application/json' };
+            const token = this.tokenInput.value.trim();
+            if (token) headers['Authorization'] = `Bearer ${token}`;
+            const res = await fetch(url, { headers, ...options });
             if (!res.ok) {
                 const err = await res.json();
                 throw new Error(err.error || 'Ошибка сервера');
@@ -88,6 +93,25 @@ class CommentTree {
         }
     }
 
+    // Обновления в реальном времени: перечитываем текущую страницу, только
+    // если событие касается показанного треда или добавляет новый тред, и не
+    // чаще раза в секунду
+    subscribeUpdates() {
+        if (!window.EventSource) return;
+        const source = new EventSource(`${this.apiUrl}/stream`);
+        const refresh = () => {
+            if (this.isSearchMode || this.refreshTimer) return;
+            this.refreshTimer = setTimeout(() => { this.refreshTimer = null; this.loadComments(); }, 1000);
+        };
+        const onEvent = e => {
+            const event = JSON.parse(e.data);
+            const newThread = e.type === 'comment.created' && !event.parent_id;
+            if (newThread || this.visibleThreads.has(event.thread_id)) refresh();
+        };
+        ['comment.created', 'comment.edited', 'comment.deleted', 'comment.restored'].forEach(type => source.addEventListener(type, onEvent));
+        source.addEventListener('reset', refresh);
+    }
+
     showLoading(show = true) {
         this.loading.style.display = show ? 'block' : 'none';
         this.noComments.style.display = !show && this.commentsContainer.children.length === 0 ? 'block' : 'none';
@@ -116,6 +140,7 @@ class CommentTree {
     renderComments(comments) {
         // Очистка
         this.commentsContainer.innerHTML = '';
+        this.visibleThreads = new Set(comments.filter(c => !c.parent_id).map(c => c.id));
         if (!comments.length) return;
 
         comments.forEach(comment => this.renderComment(comment, 0, this.commentsContainer));
@@ -123,32 +148,41 @@ class CommentTree {
 
     renderComment(comment, level, container) {
         const commentEl = document.createElement('div');
-        commentEl.className = 'comment';
+        commentEl.className = comment.deleted ? 'comment deleted-comment' : 'comment';
         commentEl.style.setProperty('--level', level);
         commentEl.dataset.id = comment.id;
 
         const totalChildren = this.countChildren(comment);
         const isCollapsed = this.collapsedComments.has(comment.id);
-        const content = comment.deleted ? '[Комментарий удален]' : comment.content;
-
+        // Разметка шаблона не содержит пользовательских данных: автор и текст
+        // вставляются ниже через textContent
         commentEl.innerHTML = `
             <div class="comment-wrapper">
                 <div class="comment-header">
                     <div class="comment-meta">
                         ${totalChildren ? `<button class="collapse-btn ${isCollapsed ? 'collapsed' : ''}" data-id="${comment.id}">${isCollapsed ? '▶' : '▼'}</button>` : '<span class="collapse-spacer">•</span>'}
-                        <span class="comment-author">${comment.author}</span>
+                        <span class="comment-author"></span>
                         <span class="comment-date">${new Date(comment.created_at).toLocaleString()}</span>
                         ${totalChildren ? `<span class="children-count">(${totalChildren} ${this.getChildrenText(totalChildren)})</span>` : ''}
                     </div>
                     <div class="comment-actions">
-                        ${!comment.deleted ? `<button class="reply-btn" data-id="${comment.id}" data-author="${comment.author}">Ответить</button>
+                        ${!comment.deleted ? `<button class="reply-btn" data-id="${comment.id}">Ответить</button>
                         <button class="delete-btn" data-id="${comment.id}">Удалить</button>` : ''}
                     </div>
                 </div>
-                <div class="comment-content">${content}</div>
+                <div class="comment-content"></div>
             </div>
         `;
 
+        commentEl.querySelector('.comment-author').textContent = comment.author;
+        const contentEl = commentEl.querySelector('.comment-content');
+        if (comment.deleted) contentEl.textContent = '[Комментарий удален]';
+        // В выдаче поиска показываем сниппет: он уже экранирован сервером
+        else if (comment.snippet) contentEl.innerHTML = comment.snippet;
+        else contentEl.textContent = comment.content;
+        const replyBtn = commentEl.querySelector('.reply-btn');
+        if (replyBtn) replyBtn.dataset.author = comment.author;
+
         // События
         if (!comment.deleted) {
             commentEl.querySelector('.reply-btn')?.addEventListener('click', () => this.openReplyModal(comment.id, comment.author, comment.content));
@@ -190,15 +224,15 @@ class CommentTree {
     }
 
     async createComment(parentId = null) {
-        const author = parentId ? this.replyAuthorInput.value.trim() : this.authorInput.value.trim();
         const content = parentId ? this.replyContentInput.value.trim() : this.contentInput.value.trim();
-        if (!author || !content) return alert('Заполните все поля');
+        if (!this.tokenInput.value.trim()) return alert('Укажите API-ключ или JWT');
+        if (!content) return alert('Напишите комментарий');
 
-        const payload = { author, content, ...(parentId && { parent_id: parentId }) };
+        const payload = { content, ...(parentId && { parent_id: parentId }) };
         await this.apiCall(this.apiUrl, { method: 'POST', body: JSON.stringify(payload) });
 
         if (parentId) this.closeReplyModal();
-        else { this.authorInput.value = ''; this.contentInput.value = ''; }
+        else this.contentInput.value = '';
 
         this.loadComments();
     }
@@ -231,10 +265,9 @@ class CommentTree {
     openReplyModal(id, author, content) {
         this.replyToId = id;
         this.replyTo.textContent = `Ответ на ${author}: "${content.slice(0,100)}${content.length>100?'...':''}"`;
-        this.replyAuthorInput.value = '';
         this.replyContentInput.value = '';
         this.replyModal.style.display = 'block';
-        this.replyAuthorInput.focus();
+        this.replyContentInput.focus();
     }
 
     closeReplyModal() { this.replyModal.style.display = 'none'; this.replyToId = null; }